@@ -0,0 +1,40 @@
+// cache_bench measures concurrent throughput of the single-lock LRU Cache against the
+// ShardedCache at 1 to 64 goroutines.
+//
+//	go run ./cmd/cache_bench -keys 100000 -writes 10 -duration 1s
+package main
+
+import (
+	"awesomeProject/lru_cache"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	keys := flag.Int("keys", 100000, "key space size")
+	capacity := flag.Int("capacity", 50000, "cache capacity in entries")
+	writePct := flag.Int("writes", 10, "percentage of operations that are Puts")
+	duration := flag.Duration("duration", time.Second, "duration of each run")
+	flag.Parse()
+
+	caches := []struct {
+		name string
+		new  func() lru_cache.Policy[int, int]
+	}{
+		{"LRU", func() lru_cache.Policy[int, int] { return lru_cache.New[int, int](*capacity) }},
+		{"ShardedLRU", func() lru_cache.Policy[int, int] { return lru_cache.NewSharded[int, int](*capacity, 0) }},
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "cache\tgoroutines\tops/sec")
+	for _, c := range caches {
+		for g := 1; g <= 64; g *= 2 {
+			res := lru_cache.MeasureThroughput(c.new(), g, *keys, *writePct, *duration)
+			fmt.Fprintf(tw, "%s\t%d\t%.0f\n", c.name, g, res.OpsPerSec())
+		}
+	}
+	tw.Flush()
+}
@@ -0,0 +1,186 @@
+// cache_sim replays an access trace through the repo's caches, sweeping cache sizes,
+// and reports hit ratio, byte hit ratio and evictions per policy and size.
+//
+//	go run ./cmd/cache_sim -trace trace.csv -sizes 100,1000,10000
+//	go run ./cmd/cache_sim -trace P1.lis -format arc -sizes 1000:64000:x2 -out csv > p1.csv
+package main
+
+import (
+	"awesomeProject/in_memory_db"
+	"awesomeProject/lru_cache"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	tracePath := flag.String("trace", "", "path to the access trace")
+	format := flag.String("format", lru_cache.FormatPlain, "trace format: plain, arc or twitter")
+	sizes := flag.String("sizes", "1000", "cache sizes in entries: comma list (100,1000) or range start:end:step, step +N or xN")
+	policies := flag.String("policies", "lru,lru-int,arc,2q,db", "comma separated policies to replay")
+	out := flag.String("out", "table", "output: table or csv")
+	flag.Parse()
+
+	if *tracePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	capacities, err := parseSizes(*sizes)
+	if err != nil {
+		fail(err)
+	}
+	factories, err := selectPolicies(*policies)
+	if err != nil {
+		fail(err)
+	}
+	trace, err := lru_cache.LoadTraceFormat(*tracePath, *format)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "replaying %d accesses from %s\n", len(trace), *tracePath)
+
+	results := lru_cache.Sweep(factories, capacities, trace)
+	switch *out {
+	case "csv":
+		lru_cache.PrintResultsCSV(os.Stdout, results)
+	default:
+		lru_cache.PrintResults(os.Stdout, results)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "cache_sim: %v\n", err)
+	os.Exit(1)
+}
+
+// allPolicies are the policies cache_sim knows about, by flag name
+func allPolicies() map[string]lru_cache.PolicyFactory {
+	m := make(map[string]lru_cache.PolicyFactory)
+	for _, f := range lru_cache.DefaultPolicies() {
+		m[strings.ToLower(f.Name)] = f
+	}
+	m["sharded"] = lru_cache.PolicyFactory{Name: "ShardedLRU", New: func(capacity int) lru_cache.ReplayCache {
+		return lru_cache.NewSharded[string, struct{}](capacity, simShards(capacity))
+	}}
+	m["lru-int"] = lru_cache.PolicyFactory{Name: "LRUCache", New: func(capacity int) lru_cache.ReplayCache {
+		return newIntLRUAdapter(capacity)
+	}}
+	m["db"] = lru_cache.PolicyFactory{Name: "in_memory_db", New: func(capacity int) lru_cache.ReplayCache {
+		return &dbAdapter{db: in_memory_db.NewDB(capacity, 0)}
+	}}
+	return m
+}
+
+// simShards picks a power-of-two shard count that divides capacity, so the shards together hold
+// exactly capacity entries. It does not depend on GOMAXPROCS, so results are the same on every machine.
+func simShards(capacity int) int {
+	shards := 16
+	for shards > 1 && capacity%shards != 0 {
+		shards /= 2
+	}
+	return shards
+}
+
+func selectPolicies(names string) ([]lru_cache.PolicyFactory, error) {
+	all := allPolicies()
+	var factories []lru_cache.PolicyFactory
+	for _, name := range strings.Split(names, ",") {
+		f, ok := all[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown policy: %s", name)
+		}
+		factories = append(factories, f)
+	}
+	return factories, nil
+}
+
+// parseSizes accepts "100,1000,10000" or "start:end:step" where step is "+N" (linear) or "xN" (geometric)
+func parseSizes(s string) ([]int, error) {
+	if !strings.Contains(s, ":") {
+		var sizes []int
+		for _, part := range strings.Split(s, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad size: %q", part)
+			}
+			sizes = append(sizes, n)
+		}
+		return sizes, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("bad size range: %q", s)
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start <= 0 || end < start {
+		return nil, fmt.Errorf("bad size range: %q", s)
+	}
+	geometric := strings.HasPrefix(parts[2], "x")
+	step, err := strconv.Atoi(strings.TrimLeft(parts[2], "+x"))
+	if err != nil || step <= 0 || (geometric && step < 2) {
+		return nil, fmt.Errorf("bad size step: %q", parts[2])
+	}
+	var sizes []int
+	for n := start; n <= end; {
+		sizes = append(sizes, n)
+		if geometric {
+			n *= step
+		} else {
+			n += step
+		}
+	}
+	return sizes, nil
+}
+
+// intLRUAdapter replays string keys through the int-only lru_cache.LRUCache
+type intLRUAdapter struct {
+	cache lru_cache.LRUCache
+	ids   map[string]int
+}
+
+func newIntLRUAdapter(capacity int) *intLRUAdapter {
+	return &intLRUAdapter{cache: lru_cache.Constructor(capacity), ids: make(map[string]int)}
+}
+
+func (a *intLRUAdapter) id(key string) int {
+	id, ok := a.ids[key]
+	if !ok {
+		id = len(a.ids)
+		a.ids[key] = id
+	}
+	return id
+}
+
+func (a *intLRUAdapter) Get(key string) (struct{}, bool) {
+	return struct{}{}, a.cache.Get(a.id(key)) != -1
+}
+
+func (a *intLRUAdapter) Put(key string, _ struct{}) {
+	a.cache.Put(a.id(key), 0)
+}
+
+func (a *intLRUAdapter) Len() int {
+	return a.cache.Len()
+}
+
+// dbAdapter replays through in_memory_db.DB, which evicts LRU by entry count
+type dbAdapter struct {
+	db *in_memory_db.DB
+}
+
+func (a *dbAdapter) Get(key string) (struct{}, bool) {
+	_, err := a.db.Get(key)
+	return struct{}{}, err == nil
+}
+
+func (a *dbAdapter) Put(key string, _ struct{}) {
+	a.db.Set(key, nil, 0)
+}
+
+func (a *dbAdapter) Len() int {
+	return len(a.db.Keys())
+}
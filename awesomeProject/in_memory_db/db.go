@@ -108,6 +108,7 @@ func (db *DB) Set(key string, value []byte, ttlSeconds int) {
 		kp := el.Value.(*kvPair)
 		oldSize := kp.item.size
 		kp.item.value = append([]byte(nil), value...)
+		kp.item.sketch = nil
 		kp.item.expiresAt = expires
 		kp.item.ver++
 		kp.item.size = len(value)
@@ -148,6 +149,11 @@ func (db *DB) Get(key string) ([]byte, error) {
 		return nil, ErrKeyNotFound
 	}
 
+	if kp.item.sketch != nil {
+		db.stats.Misses++
+		return nil, ErrWrongType
+	}
+
 	// hit
 	db.lru.MoveToFront(el)
 	db.stats.Hits++
@@ -220,6 +226,7 @@ func (db *DB) CAS(key string, expectedVer uint64, newValue []byte, ttlSeconds in
 
 	oldSize := kp.item.size
 	kp.item.value = append([]byte(nil), newValue...)
+	kp.item.sketch = nil
 	kp.item.ver++
 	kp.item.size = len(newValue)
 	kp.item.expiresAt = time.Time{}
@@ -248,6 +255,7 @@ func (db *DB) Commit(tx *Tx) {
 				kp := el.Value.(*kvPair)
 				oldSize := kp.item.size
 				kp.item.value = append([]byte(nil), op.Value...)
+				kp.item.sketch = nil
 				kp.item.expiresAt = expires
 				kp.item.ver++
 				kp.item.size = len(op.Value)
@@ -53,6 +53,22 @@ func In_mem_db() {
 		fmt.Println("name deleted by tx")
 	}
 
+	// Probabilistic structures
+	db.BFReserve("seen", 1000, 0.01)
+	db.BFAdd("seen", []byte("req-1"))
+	if ok, _ := db.BFExists("seen", []byte("req-1")); ok {
+		fmt.Println("req-1 probably seen")
+	}
+	db.CMSInitByProb("hits", 0.001, 0.01)
+	db.CMSIncrBy("hits", []byte("/home"), 3)
+	n, _ := db.CMSQuery("hits", []byte("/home"))
+	fmt.Printf("/home hits ~%d\n", n)
+	for i := 0; i < 1000; i++ {
+		db.PFAdd("visitors", []byte(fmt.Sprintf("user-%d", i)))
+	}
+	card, _ := db.PFCount("visitors")
+	fmt.Printf("distinct visitors ~%d\n", card)
+
 	// Stats
 	st := db.Stats()
 	fmt.Printf("stats: %+v\n", st)
@@ -11,12 +11,18 @@ type Item struct {
 	expiresAt time.Time // zero means no expiry
 	ver       uint64    // simple version for CAS
 	size      int
+	sketch    sketch // non-nil for probabilistic values (bloom, cms, hll)
 }
 
 // ErrKeyNotFound returned when key does not exist or expired
 var ErrKeyNotFound = errors.New("key not found")
 var ErrCASFailed = errors.New("cas failed")
 
+// ErrWrongType returned when a key holds a value of a different type than the operation expects
+var ErrWrongType = errors.New("wrong type for key")
+var ErrKeyExists = errors.New("key already exists")
+var ErrInvalidParam = errors.New("invalid parameter")
+
 // pair stored in LRU list
 type kvPair struct {
 	key  string
@@ -0,0 +1,442 @@
+package in_memory_db
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Probabilistic value types stored under keys, modelled on the RedisBloom / HyperLogLog commands:
+// - Bloom filter (BFReserve / BFAdd / BFExists) sized from capacity + false-positive rate
+// - Count-Min sketch (CMSInitByProb / CMSInitByDim / CMSIncrBy / CMSQuery) for frequency estimates
+// - HyperLogLog (PFAdd / PFCount / PFMerge) for distinct counts
+// All operations take the write lock so add+query are atomic, and the sketch size is
+// accounted in Stats.Bytes exactly like a plain value.
+
+// defaults used when BFAdd creates a filter implicitly
+const (
+	DefaultBloomCapacity  = 100
+	DefaultBloomErrorRate = 0.01
+	hllPrecision          = 14 // 2^14 registers, ~0.81% standard error
+)
+
+// sketch is implemented by every probabilistic value
+type sketch interface {
+	sizeBytes() int
+}
+
+// ------------------- Bloom filter ------------------
+
+type bloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+func newBloomFilter(capacity uint64, errorRate float64) *bloomFilter {
+	// m = -n*ln(p) / ln(2)^2, k = m/n * ln(2)
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// add sets the item's bits and reports whether any bit was previously unset (i.e. item is new)
+func (b *bloomFilter) add(item []byte) bool {
+	h1, h2 := hash128(item)
+	added := false
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		word, mask := pos/64, uint64(1)<<(pos%64)
+		if b.bits[word]&mask == 0 {
+			b.bits[word] |= mask
+			added = true
+		}
+	}
+	return added
+}
+
+func (b *bloomFilter) exists(item []byte) bool {
+	h1, h2 := hash128(item)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) sizeBytes() int { return len(b.bits) * 8 }
+
+// ------------------- Count-Min sketch ------------------
+
+type countMinSketch struct {
+	width  uint64
+	depth  uint64
+	counts []uint64 // depth rows of width counters, row-major
+}
+
+func newCountMinSketch(width, depth uint64) *countMinSketch {
+	return &countMinSketch{width: width, depth: depth, counts: make([]uint64, width*depth)}
+}
+
+// incr adds n to the item's counters and returns the new estimate
+func (c *countMinSketch) incr(item []byte, n uint64) uint64 {
+	h1, h2 := hash128(item)
+	est := uint64(math.MaxUint64)
+	for row := uint64(0); row < c.depth; row++ {
+		idx := row*c.width + (h1+row*h2)%c.width
+		c.counts[idx] += n
+		if c.counts[idx] < est {
+			est = c.counts[idx]
+		}
+	}
+	return est
+}
+
+// query returns the minimum counter across rows, which never under-estimates
+func (c *countMinSketch) query(item []byte) uint64 {
+	h1, h2 := hash128(item)
+	est := uint64(math.MaxUint64)
+	for row := uint64(0); row < c.depth; row++ {
+		if v := c.counts[row*c.width+(h1+row*h2)%c.width]; v < est {
+			est = v
+		}
+	}
+	return est
+}
+
+func (c *countMinSketch) sizeBytes() int { return len(c.counts) * 8 }
+
+// ------------------- HyperLogLog ------------------
+
+type hyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{p: hllPrecision, registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// add reports whether a register changed (the estimate may have moved)
+func (h *hyperLogLog) add(item []byte) bool {
+	x := hash64(item)
+	idx := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+		return true
+	}
+	return false
+}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) {
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+func (h *hyperLogLog) count() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+	// small range correction: linear counting
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+func (h *hyperLogLog) sizeBytes() int { return len(h.registers) }
+
+// ------------------- DB commands ------------------
+
+// BFReserve creates an empty bloom filter sized for capacity items at the given false-positive rate
+func (db *DB) BFReserve(key string, capacity uint64, errorRate float64) error {
+	if capacity == 0 || errorRate <= 0 || errorRate >= 1 {
+		return ErrInvalidParam
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.lookup(key); ok {
+		return ErrKeyExists
+	}
+	db.putSketch(key, newBloomFilter(capacity, errorRate))
+	return nil
+}
+
+// BFAdd adds item to the filter at key, creating it with default sizing if missing.
+// Returns true if the item was not already present.
+func (db *DB) BFAdd(key string, item []byte) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var bf *bloomFilter
+	if el, ok := db.lookup(key); ok {
+		kp := el.Value.(*kvPair)
+		if bf, ok = kp.item.sketch.(*bloomFilter); !ok {
+			return false, ErrWrongType
+		}
+		kp.item.ver++
+		db.lru.MoveToFront(el)
+	} else {
+		bf = newBloomFilter(DefaultBloomCapacity, DefaultBloomErrorRate)
+		db.putSketch(key, bf)
+	}
+	db.stats.Sets++
+	return bf.add(item), nil
+}
+
+// BFExists reports whether item may be in the filter (false positives possible, no false negatives)
+func (db *DB) BFExists(key string, item []byte) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.stats.Gets++
+	el, ok := db.lookup(key)
+	if !ok {
+		db.stats.Misses++
+		return false, nil
+	}
+	bf, ok := el.Value.(*kvPair).item.sketch.(*bloomFilter)
+	if !ok {
+		db.stats.Misses++
+		return false, ErrWrongType
+	}
+	db.lru.MoveToFront(el)
+	db.stats.Hits++
+	return bf.exists(item), nil
+}
+
+// CMSInitByDim creates a count-min sketch with explicit width and depth
+func (db *DB) CMSInitByDim(key string, width, depth uint64) error {
+	if width == 0 || depth == 0 {
+		return ErrInvalidParam
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.lookup(key); ok {
+		return ErrKeyExists
+	}
+	db.putSketch(key, newCountMinSketch(width, depth))
+	return nil
+}
+
+// CMSInitByProb creates a count-min sketch whose estimates exceed the true count by at most
+// epsilon*total with probability 1-delta
+func (db *DB) CMSInitByProb(key string, epsilon, delta float64) error {
+	if epsilon <= 0 || epsilon >= 1 || delta <= 0 || delta >= 1 {
+		return ErrInvalidParam
+	}
+	width := uint64(math.Ceil(math.E / epsilon))
+	depth := uint64(math.Ceil(math.Log(1 / delta)))
+	return db.CMSInitByDim(key, width, depth)
+}
+
+// CMSIncrBy adds incr to item's count and returns the new estimate. The sketch must exist.
+func (db *DB) CMSIncrBy(key string, item []byte, incr uint64) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	el, ok := db.lookup(key)
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	kp := el.Value.(*kvPair)
+	cms, ok := kp.item.sketch.(*countMinSketch)
+	if !ok {
+		return 0, ErrWrongType
+	}
+	kp.item.ver++
+	db.lru.MoveToFront(el)
+	db.stats.Sets++
+	return cms.incr(item, incr), nil
+}
+
+// CMSQuery returns the estimated count for item
+func (db *DB) CMSQuery(key string, item []byte) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.stats.Gets++
+	el, ok := db.lookup(key)
+	if !ok {
+		db.stats.Misses++
+		return 0, ErrKeyNotFound
+	}
+	cms, ok := el.Value.(*kvPair).item.sketch.(*countMinSketch)
+	if !ok {
+		db.stats.Misses++
+		return 0, ErrWrongType
+	}
+	db.lru.MoveToFront(el)
+	db.stats.Hits++
+	return cms.query(item), nil
+}
+
+// PFAdd adds items to the HyperLogLog at key, creating it if missing.
+// Returns true if the estimated cardinality may have changed.
+func (db *DB) PFAdd(key string, items ...[]byte) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	hll, created, err := db.hllForWrite(key)
+	if err != nil {
+		return false, err
+	}
+	changed := created
+	for _, it := range items {
+		if hll.add(it) {
+			changed = true
+		}
+	}
+	db.stats.Sets++
+	return changed, nil
+}
+
+// PFCount returns the approximate number of distinct items in the union of the given keys.
+// Missing keys count as empty.
+func (db *DB) PFCount(keys ...string) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.stats.Gets++
+	union := newHyperLogLog()
+	found := false
+	for _, key := range keys {
+		el, ok := db.lookup(key)
+		if !ok {
+			continue
+		}
+		hll, ok := el.Value.(*kvPair).item.sketch.(*hyperLogLog)
+		if !ok {
+			db.stats.Misses++
+			return 0, ErrWrongType
+		}
+		db.lru.MoveToFront(el)
+		union.merge(hll)
+		found = true
+	}
+	if !found {
+		db.stats.Misses++
+		return 0, nil
+	}
+	db.stats.Hits++
+	return union.count(), nil
+}
+
+// PFMerge merges the source HyperLogLogs into dest, creating dest if missing
+func (db *DB) PFMerge(dest string, srcs ...string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// validate sources before touching dest so a type error leaves dest untouched
+	merged := newHyperLogLog()
+	for _, key := range srcs {
+		el, ok := db.lookup(key)
+		if !ok {
+			continue
+		}
+		hll, ok := el.Value.(*kvPair).item.sketch.(*hyperLogLog)
+		if !ok {
+			return ErrWrongType
+		}
+		merged.merge(hll)
+	}
+
+	hll, _, err := db.hllForWrite(dest)
+	if err != nil {
+		return err
+	}
+	hll.merge(merged)
+	db.stats.Sets++
+	return nil
+}
+
+// internals
+
+// lookup returns the live element for key, removing it if expired. Caller must hold db.mu.
+func (db *DB) lookup(key string) (*list.Element, bool) {
+	el, ok := db.data[key]
+	if !ok {
+		return nil, false
+	}
+	kp := el.Value.(*kvPair)
+	if !kp.item.expiresAt.IsZero() && time.Now().After(kp.item.expiresAt) {
+		db.removeElement(el)
+		return nil, false
+	}
+	return el, true
+}
+
+// putSketch stores a new sketch under key (which must not exist). Caller must hold db.mu.
+func (db *DB) putSketch(key string, sk sketch) {
+	item := &Item{sketch: sk, ver: 1, size: sk.sizeBytes()}
+	el := db.lru.PushFront(&kvPair{key: key, item: item})
+	db.data[key] = el
+	db.stats.Bytes += uint64(item.size)
+	if db.capacity > 0 && db.lru.Len() > db.capacity {
+		db.evictLRU()
+	}
+}
+
+// hllForWrite returns the HyperLogLog at key, creating it if missing. Caller must hold db.mu.
+func (db *DB) hllForWrite(key string) (*hyperLogLog, bool, error) {
+	if el, ok := db.lookup(key); ok {
+		kp := el.Value.(*kvPair)
+		hll, ok := kp.item.sketch.(*hyperLogLog)
+		if !ok {
+			return nil, false, ErrWrongType
+		}
+		kp.item.ver++
+		db.lru.MoveToFront(el)
+		return hll, false, nil
+	}
+	hll := newHyperLogLog()
+	db.putSketch(key, hll)
+	return hll, true, nil
+}
+
+// hash128 returns two independent 64-bit hashes for double hashing (Kirsch-Mitzenmacher)
+func hash128(item []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(item)
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1 // odd so probes don't collapse
+	return h1, h2
+}
+
+// hash64 is fnv-1a followed by the murmur3 finalizer so the high bits are well mixed for HLL
+func hash64(item []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(item)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
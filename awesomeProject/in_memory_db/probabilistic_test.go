@@ -0,0 +1,180 @@
+package in_memory_db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func item(prefix string, i int) []byte { return []byte(fmt.Sprint(prefix, i)) }
+
+func TestBloomFalsePositiveRateAtCapacity(t *testing.T) {
+	const capacity, errorRate = 10000, 0.01
+	db := NewDB(0, 0)
+	if err := db.BFReserve("bf", capacity, errorRate); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < capacity; i++ {
+		if _, err := db.BFAdd("bf", item("in", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < capacity; i++ {
+		if ok, _ := db.BFExists("bf", item("in", i)); !ok {
+			t.Fatalf("false negative for item %d", i)
+		}
+	}
+	falsePositives := 0
+	const probes = 100000
+	for i := 0; i < probes; i++ {
+		if ok, _ := db.BFExists("bf", item("out", i)); ok {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / probes; rate > 1.5*errorRate {
+		t.Fatalf("false-positive rate %.4f at capacity, configured %.2f", rate, errorRate)
+	}
+}
+
+func TestCMSNeverUnderestimates(t *testing.T) {
+	db := NewDB(0, 0)
+	if err := db.CMSInitByDim("cms", 50, 4); err != nil {
+		t.Fatal(err)
+	}
+	// far more items than counters, so collisions are certain
+	truth := make(map[string]uint64)
+	for i := 0; i < 2000; i++ {
+		it := item("k", i%500)
+		n := uint64(i%7 + 1)
+		truth[string(it)] += n
+		if _, err := db.CMSIncrBy("cms", it, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for it, want := range truth {
+		got, err := db.CMSQuery("cms", []byte(it))
+		if err != nil || got < want {
+			t.Fatalf("CMSQuery(%s) = %d, %v; true count %d", it, got, err, want)
+		}
+	}
+	if got, _ := db.CMSQuery("cms", []byte("never-added")); got == math.MaxUint64 {
+		t.Fatal("query of an unseen item returned the sentinel")
+	}
+}
+
+func TestCMSInitByProbDimensions(t *testing.T) {
+	db := NewDB(0, 0)
+	for _, tc := range []struct {
+		epsilon, delta float64
+		width, depth   uint64
+	}{
+		{0.01, 0.01, 272, 5},
+		{0.001, 0.1, 2719, 3},
+		{0.5, 0.5, 6, 1},
+	} {
+		key := fmt.Sprint("cms", tc.epsilon, tc.delta)
+		if err := db.CMSInitByProb(key, tc.epsilon, tc.delta); err != nil {
+			t.Fatal(err)
+		}
+		cms := db.data[key].Value.(*kvPair).item.sketch.(*countMinSketch)
+		if cms.width != tc.width || cms.depth != tc.depth || len(cms.counts) != int(tc.width*tc.depth) {
+			t.Errorf("epsilon %v delta %v: %dx%d, want %dx%d", tc.epsilon, tc.delta, cms.width, cms.depth, tc.width, tc.depth)
+		}
+	}
+	for _, bad := range [][2]float64{{0, 0.1}, {1, 0.1}, {0.1, 0}, {0.1, 1}} {
+		if err := db.CMSInitByProb("bad", bad[0], bad[1]); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("CMSInitByProb(%v, %v) = %v", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	const n = 100000
+	db := NewDB(0, 0)
+	for i := 0; i < n; i++ {
+		if _, err := db.PFAdd("hll", item("u", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// adding the same items again does not move the estimate
+	if changed, _ := db.PFAdd("hll", item("u", 0), item("u", n-1)); changed {
+		t.Error("re-adding seen items changed the registers")
+	}
+	got, err := db.PFCount("hll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := math.Abs(float64(got)-n) / n; e > 0.02 {
+		t.Fatalf("PFCount = %d for %d distinct items, error %.2f%%", got, n, e*100)
+	}
+}
+
+func TestPFMergeIsUnion(t *testing.T) {
+	db := NewDB(0, 0)
+	for i := 0; i < 60000; i++ {
+		db.PFAdd("a", item("u", i))
+	}
+	for i := 40000; i < 100000; i++ {
+		db.PFAdd("b", item("u", i))
+	}
+	union, _ := db.PFCount("a", "b")
+	if err := db.PFMerge("ab", "a", "b", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	merged, _ := db.PFCount("ab")
+	if merged != union {
+		t.Fatalf("PFMerge counts %d, PFCount of the sources %d", merged, union)
+	}
+	if e := math.Abs(float64(merged)-100000) / 100000; e > 0.02 {
+		t.Fatalf("merged count %d, want about 100000", merged)
+	}
+
+	db.Set("plain", []byte("v"), 0)
+	if err := db.PFMerge("ab", "a", "plain"); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("merging a plain value: %v", err)
+	}
+	if after, _ := db.PFCount("ab"); after != merged {
+		t.Fatalf("failed merge changed dest from %d to %d", merged, after)
+	}
+}
+
+func TestSketchKeysAndPlainValues(t *testing.T) {
+	db := NewDB(0, 0)
+	db.BFAdd("bf", []byte("x"))
+	db.CMSInitByDim("cms", 10, 2)
+	db.PFAdd("hll", []byte("x"))
+	for _, key := range []string{"bf", "cms", "hll"} {
+		if _, err := db.Get(key); !errors.Is(err, ErrWrongType) {
+			t.Errorf("Get(%s) = %v, want ErrWrongType", key, err)
+		}
+	}
+	if s := db.Stats(); s.Gets != 3 || s.Misses != 3 || s.Hits != 0 {
+		t.Errorf("stats after reading sketches as plain values: %+v", s)
+	}
+
+	db.Set("plain", []byte("v"), 0)
+	if _, err := db.BFExists("plain", []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("BFExists on a plain value: %v", err)
+	}
+	if _, err := db.CMSQuery("bf", []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("CMSQuery on a bloom filter: %v", err)
+	}
+	if _, err := db.PFAdd("cms", []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("PFAdd on a count-min sketch: %v", err)
+	}
+	if s := db.Stats(); s.Hits+s.Misses != s.Gets {
+		t.Errorf("hits %d + misses %d != gets %d", s.Hits, s.Misses, s.Gets)
+	}
+
+	// Set overwrites a sketch and frees its bytes
+	for _, key := range []string{"bf", "cms", "hll"} {
+		db.Set(key, []byte("plain"), 0)
+		if v, err := db.Get(key); err != nil || string(v) != "plain" {
+			t.Errorf("Get(%s) after Set = %q, %v", key, v, err)
+		}
+	}
+	if s := db.Stats(); s.Bytes != 3*uint64(len("plain"))+uint64(len("v")) {
+		t.Errorf("Bytes = %d after replacing every sketch", s.Bytes)
+	}
+}
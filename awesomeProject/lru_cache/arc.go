@@ -0,0 +1,152 @@
+package lru_cache
+
+import "sync"
+
+// ARC is an Adaptive Replacement Cache (Megiddo & Modha, FAST '03).
+//   - t1 holds entries seen once recently, t2 entries seen at least twice
+//   - b1/b2 are ghost lists remembering keys recently evicted from t1/t2 (no values)
+//   - p is the target size of t1; a ghost hit in b1 grows it (favour recency),
+//     a ghost hit in b2 shrinks it (favour frequency)
+//
+// A one-off scan only churns t1, so the frequently used working set in t2 survives.
+type ARC[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	p        int
+	t1       *indexedList[K, V]
+	t2       *indexedList[K, V]
+	b1       *indexedList[K, V]
+	b2       *indexedList[K, V]
+}
+
+func NewARC[K comparable, V any](capacity int) *ARC[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ARC[K, V]{
+		capacity: capacity,
+		t1:       newIndexedList[K, V](),
+		t2:       newIndexedList[K, V](),
+		b1:       newIndexedList[K, V](),
+		b2:       newIndexedList[K, V](),
+	}
+}
+
+// Get returns the value for key; a hit promotes the entry to the frequent list
+func (c *ARC[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.t1.index[key]; ok {
+		c.t1.unlink(node)
+		c.t2.pushFront(node.key, node.val)
+		return node.val, true
+	}
+	if node, ok := c.t2.index[key]; ok {
+		c.t2.moveToHead(node)
+		return node.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Peek returns the value for key without changing its position
+func (c *ARC[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.t1.index[key]; ok {
+		return node.val, true
+	}
+	if node, ok := c.t2.index[key]; ok {
+		return node.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *ARC[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// resident: update and treat as a second reference
+	if node, ok := c.t1.index[key]; ok {
+		c.t1.unlink(node)
+		c.t2.pushFront(key, value)
+		return
+	}
+	if node, ok := c.t2.index[key]; ok {
+		node.val = value
+		c.t2.moveToHead(node)
+		return
+	}
+
+	// ghost hit in b1: recency list was too small
+	if node, ok := c.b1.index[key]; ok {
+		c.p = min(c.capacity, c.p+max(c.b2.size/c.b1.size, 1))
+		c.replace(false)
+		c.b1.unlink(node)
+		c.t2.pushFront(key, value)
+		return
+	}
+	// ghost hit in b2: frequency list was too small
+	if node, ok := c.b2.index[key]; ok {
+		c.p = max(0, c.p-max(c.b1.size/c.b2.size, 1))
+		c.replace(true)
+		c.b2.unlink(node)
+		c.t2.pushFront(key, value)
+		return
+	}
+
+	// complete miss
+	if c.t1.size+c.b1.size == c.capacity {
+		if c.t1.size < c.capacity {
+			c.b1.popTail()
+			c.replace(false)
+		} else {
+			c.t1.popTail()
+		}
+	} else if total := c.t1.size + c.t2.size + c.b1.size + c.b2.size; total >= c.capacity {
+		if total == 2*c.capacity {
+			c.b2.popTail()
+		}
+		c.replace(false)
+	}
+	c.t1.pushFront(key, value)
+}
+
+func (c *ARC[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, l := range []*indexedList[K, V]{c.t1, c.t2, c.b1, c.b2} {
+		if node, ok := l.index[key]; ok {
+			l.unlink(node)
+			// only resident entries count as present
+			return l == c.t1 || l == c.t2
+		}
+	}
+	return false
+}
+
+// Len returns the number of resident entries
+func (c *ARC[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t1.size + c.t2.size
+}
+
+// replace evicts one resident entry into its ghost list, choosing t1 or t2 based on p
+func (c *ARC[K, V]) replace(inB2 bool) {
+	if c.t1.size+c.t2.size < c.capacity {
+		return // room left, e.g. after Delete
+	}
+	if c.t1.size > 0 && (c.t1.size > c.p || (inB2 && c.t1.size == c.p)) {
+		node := c.t1.popTail()
+		c.b1.pushFront(node.key, *new(V))
+		return
+	}
+	if node := c.t2.popTail(); node != nil {
+		c.b2.pushFront(node.key, *new(V))
+	}
+}
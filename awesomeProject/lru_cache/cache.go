@@ -0,0 +1,194 @@
+package lru_cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a generic, concurrency-safe LRU cache.
+// - Get returns (value, ok) instead of a -1 sentinel
+// - Peek reads without touching recency
+// - optional default TTL (WithTTL) and per-entry TTL (PutWithTTL), expired entries are dropped lazily
+// - optional eviction callback for capacity evictions and expirations
+// capacity <= 0 means unbounded; note that LRUCache (Constructor) keeps its old meaning of
+// holding nothing for capacity <= 0.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	list     *linkedList[K, V]
+	cache    map[K]*dll[K, V]
+	capacity int
+	ttl      time.Duration
+	onEvict  func(key K, value V)
+	now      func() time.Time
+}
+
+type Option[K comparable, V any] func(*Cache[K, V])
+
+// WithTTL sets the default time-to-live applied by Put
+func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.ttl = ttl
+	}
+}
+
+// WithEvictCallback registers fn to be called (outside the lock) for every entry evicted
+// due to capacity, Resize or expiry. Explicit Delete does not trigger it.
+func WithEvictCallback[K comparable, V any](fn func(key K, value V)) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.onEvict = fn
+	}
+}
+
+// WithClock overrides time.Now, mostly for tests and trace replay
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(c *Cache[K, V]) {
+		c.now = now
+	}
+}
+
+func New[K comparable, V any](capacity int, options ...Option[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		list:     newLinkedList[K, V](),
+		cache:    make(map[K]*dll[K, V]),
+		capacity: capacity,
+		now:      time.Now,
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// Get returns the value for key and marks it most recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	node, ok := c.lookup(key)
+	if !ok {
+		c.mu.Unlock()
+		c.fireEvicted(node)
+		var zero V
+		return zero, false
+	}
+	c.list.moveToHead(node)
+	val := node.val // node may be updated or reused once the lock is released
+	c.mu.Unlock()
+	return val, true
+}
+
+// Peek returns the value for key without updating recency
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	node, ok := c.lookup(key)
+	var val V
+	if ok {
+		val = node.val
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.fireEvicted(node)
+		return val, false
+	}
+	return val, true
+}
+
+// Put inserts or updates key using the default TTL
+func (c *Cache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL inserts or updates key with its own TTL (0 means no expiry)
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	if node, ok := c.cache[key]; ok {
+		node.val = value
+		node.expiresAt = expiresAt
+		c.list.moveToHead(node)
+		c.mu.Unlock()
+		return
+	}
+	node := &dll[K, V]{key: key, val: value, expiresAt: expiresAt}
+	c.cache[key] = node
+	c.list.addNode(node)
+	evicted := c.trim()
+	c.mu.Unlock()
+	c.fireEvicted(evicted...)
+}
+
+// Delete removes key and reports whether it was present
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	delete(c.cache, key)
+	c.list.removeNode(node)
+	return true
+}
+
+// Len returns the number of entries, including expired ones not yet reclaimed
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cache)
+}
+
+// Resize changes the capacity, evicting least recently used entries if needed.
+// Returns the number of evicted entries.
+func (c *Cache[K, V]) Resize(capacity int) int {
+	c.mu.Lock()
+	c.capacity = capacity
+	evicted := c.trim()
+	c.mu.Unlock()
+	c.fireEvicted(evicted...)
+	return len(evicted)
+}
+
+// internals, caller must hold c.mu
+
+// lookup returns the live node for key. An expired node is unlinked and returned with ok=false
+// so the caller can fire the eviction callback after unlocking.
+func (c *Cache[K, V]) lookup(key K) (*dll[K, V], bool) {
+	node, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !node.expiresAt.IsZero() && c.now().After(node.expiresAt) {
+		delete(c.cache, key)
+		c.list.removeNode(node)
+		return node, false
+	}
+	return node, true
+}
+
+// trim evicts from the tail until the cache fits its capacity
+func (c *Cache[K, V]) trim() []*dll[K, V] {
+	var evicted []*dll[K, V]
+	for c.capacity > 0 && len(c.cache) > c.capacity {
+		evicted = append(evicted, c.moveTail())
+	}
+	return evicted
+}
+
+func (c *Cache[K, V]) fireEvicted(nodes ...*dll[K, V]) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, node := range nodes {
+		if node != nil {
+			c.onEvict(node.key, node.val)
+		}
+	}
+}
+
+func (c *Cache[K, V]) moveTail() *dll[K, V] {
+	rm := c.list.removeTail()
+	delete(c.cache, rm.key)
+	return rm
+}
@@ -0,0 +1,151 @@
+package lru_cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testClock is a manual clock for WithClock
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestGetUpdatesRecencyPeekDoesNot(t *testing.T) {
+	c := New[string, int](2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Peek("a") // a stays least recently used
+	c.Put("c", 3)
+	if _, ok := c.Peek("a"); ok {
+		t.Fatal("Peek refreshed a, so b was evicted instead")
+	}
+
+	c.Get("b") // now c is least recently used
+	c.Put("d", 4)
+	if _, ok := c.Peek("c"); ok {
+		t.Fatal("Get did not refresh b")
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Fatalf("Get(b) = %d, %v", v, ok)
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	clock := &testClock{t: time.Unix(1_700_000_000, 0)}
+	c := New[string, int](0, WithTTL[string, int](time.Minute), WithClock[string, int](clock.now))
+	c.Put("default", 1)
+	c.PutWithTTL("short", 2, time.Second)
+	c.PutWithTTL("forever", 3, 0)
+
+	clock.advance(time.Second)
+	if _, ok := c.Get("short"); !ok {
+		t.Fatal("entry expired at its deadline, want after it")
+	}
+	clock.advance(time.Nanosecond)
+	if _, ok := c.Peek("short"); ok {
+		t.Fatal("short-lived entry still readable after its TTL")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want the expired entry reclaimed on read", c.Len())
+	}
+
+	clock.advance(time.Minute)
+	if _, ok := c.Get("default"); ok {
+		t.Fatal("entry outlived the default TTL")
+	}
+	if v, ok := c.Get("forever"); !ok || v != 3 {
+		t.Fatalf("entry without TTL: %d, %v", v, ok)
+	}
+
+	// an update restarts the TTL
+	c.Put("default", 4)
+	clock.advance(30 * time.Second)
+	c.Put("default", 5)
+	clock.advance(45 * time.Second)
+	if v, ok := c.Get("default"); !ok || v != 5 {
+		t.Fatalf("updated entry: %d, %v", v, ok)
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := New[int, int](5)
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+	}
+	c.Get(0)
+	if n := c.Resize(2); n != 3 || c.Len() != 2 {
+		t.Fatalf("Resize(2) evicted %d, Len %d", n, c.Len())
+	}
+	for _, k := range []int{0, 4} {
+		if _, ok := c.Peek(k); !ok {
+			t.Fatalf("key %d evicted, want the two most recently used kept", k)
+		}
+	}
+	if n := c.Resize(0); n != 0 {
+		t.Fatalf("Resize(0) evicted %d", n)
+	}
+	for i := 10; i < 20; i++ {
+		c.Put(i, i)
+	}
+	if c.Len() != 12 {
+		t.Fatalf("unbounded cache holds %d, want 12", c.Len())
+	}
+}
+
+func TestEvictCallback(t *testing.T) {
+	clock := &testClock{t: time.Unix(1_700_000_000, 0)}
+	var evicted []string
+	c := New[string, int](2,
+		WithClock[string, int](clock.now),
+		WithEvictCallback(func(k string, v int) { evicted = append(evicted, fmt.Sprint(k, "=", v)) }),
+	)
+	expect := func(reason string, want ...string) {
+		t.Helper()
+		if fmt.Sprint(evicted) != fmt.Sprint(want) {
+			t.Fatalf("%s: evicted %v, want %v", reason, evicted, want)
+		}
+		evicted = nil
+	}
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("a", 10) // update, not an eviction
+	expect("update")
+	c.Put("c", 3)
+	expect("capacity", "b=2")
+
+	c.Delete("a")
+	expect("Delete")
+
+	c.PutWithTTL("d", 4, time.Second)
+	c.Resize(1)
+	expect("Resize", "c=3")
+
+	clock.advance(2 * time.Second)
+	c.Get("d")
+	expect("expiry on Get", "d=4")
+	c.PutWithTTL("e", 5, time.Second)
+	clock.advance(2 * time.Second)
+	c.Peek("e")
+	expect("expiry on Peek", "e=5")
+	c.Get("missing")
+	expect("miss")
+}
+
+func TestLRUCacheWithoutCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		c := Constructor(capacity)
+		c.Put(1, 1)
+		if got := c.Get(1); got != -1 || c.Len() != 0 {
+			t.Fatalf("Constructor(%d): Get = %d, Len = %d; want an empty cache", capacity, got, c.Len())
+		}
+	}
+	c := Constructor(1)
+	c.Put(1, 1)
+	c.Put(2, 2)
+	if c.Get(1) != -1 || c.Get(2) != 2 {
+		t.Fatal("Constructor(1) did not evict the older key")
+	}
+}
@@ -0,0 +1,77 @@
+package lru_cache
+
+// linkedList is the sentinel-based doubly linked list shared by the cache policies.
+// Tail <-> ... <-> Node <-> Head, head side is most recently used.
+type linkedList[K comparable, V any] struct {
+	head *dll[K, V]
+	tail *dll[K, V]
+	size int
+}
+
+func newLinkedList[K comparable, V any]() *linkedList[K, V] {
+	l := &linkedList[K, V]{head: &dll[K, V]{}, tail: &dll[K, V]{}}
+	l.head.prev = l.tail
+	l.tail.next = l.head
+	return l
+}
+
+func (l *linkedList[K, V]) addNode(node *dll[K, V]) {
+	node.next = l.head
+	node.prev = l.head.prev
+	l.head.prev.next = node
+	l.head.prev = node
+	l.size++
+}
+
+func (l *linkedList[K, V]) removeNode(node *dll[K, V]) {
+	pr, nx := node.prev, node.next
+	nx.prev = pr
+	pr.next = nx
+	l.size--
+}
+
+func (l *linkedList[K, V]) moveToHead(node *dll[K, V]) {
+	l.removeNode(node)
+	l.addNode(node)
+}
+
+// removeTail unlinks and returns the least recently used node, nil if empty
+func (l *linkedList[K, V]) removeTail() *dll[K, V] {
+	if l.size == 0 {
+		return nil
+	}
+	rm := l.tail.next
+	l.removeNode(rm)
+	return rm
+}
+
+// indexedList is a linkedList with its own key index, used by the multi-list policies (ARC, 2Q)
+type indexedList[K comparable, V any] struct {
+	*linkedList[K, V]
+	index map[K]*dll[K, V]
+}
+
+func newIndexedList[K comparable, V any]() *indexedList[K, V] {
+	return &indexedList[K, V]{linkedList: newLinkedList[K, V](), index: make(map[K]*dll[K, V])}
+}
+
+func (l *indexedList[K, V]) pushFront(key K, val V) *dll[K, V] {
+	node := &dll[K, V]{key: key, val: val}
+	l.index[key] = node
+	l.addNode(node)
+	return node
+}
+
+func (l *indexedList[K, V]) unlink(node *dll[K, V]) {
+	delete(l.index, node.key)
+	l.removeNode(node)
+}
+
+// popTail unlinks and returns the least recently used node, nil if empty
+func (l *indexedList[K, V]) popTail() *dll[K, V] {
+	node := l.removeTail()
+	if node != nil {
+		delete(l.index, node.key)
+	}
+	return node
+}
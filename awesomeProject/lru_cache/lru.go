@@ -1,81 +1,53 @@
 package lru_cache
 
-type dll struct {
-	key  int
-	val  int
-	next *dll
-	prev *dll
+import "time"
+
+// dll is a node of linkedList
+type dll[K comparable, V any] struct {
+	key       K
+	val       V
+	expiresAt time.Time // zero means no expiry
+	next      *dll[K, V]
+	prev      *dll[K, V]
 }
 
+// LRUCache is the original int-only cache, kept for existing callers.
+// It is a thin wrapper over Cache[int, int]; new code should use Cache directly.
 type LRUCache struct {
-	head     *dll
-	tail     *dll
-	cache    map[int]*dll
-	capacity int
+	cache *Cache[int, int] // nil when capacity <= 0: the cache holds nothing
 }
 
 // ------------------- LRUCache ------------------
-// Tail <-> ... <-> Node <-> Head
-// Head is most recently used
 
+// Constructor creates a cache holding up to capacity entries. Unlike New, capacity <= 0 gives
+// a cache that stores nothing, as it always has.
 func Constructor(capacity int) LRUCache {
-	cache := make(map[int]*dll)
-	lru := LRUCache{
-		head:     &dll{0, 0, nil, nil},
-		tail:     &dll{0, 0, nil, nil},
-		cache:    cache,
-		capacity: capacity,
+	if capacity <= 0 {
+		return LRUCache{}
 	}
-	lru.head.prev = lru.tail
-	lru.tail.next = lru.head
-	return lru
-}
-
-func (this *LRUCache) addNode(node *dll) {
-	node.next = this.head
-	node.prev = this.head.prev
-	this.head.prev.next = node
-	this.head.prev = node
-}
-
-func (this *LRUCache) removeNode(node *dll) {
-	pr, nx := node.prev, node.next
-	nx.prev = pr
-	pr.next = nx
-}
-
-func (this *LRUCache) moveToHead(node *dll) {
-	this.removeNode(node)
-	this.addNode(node)
-}
-
-func (this *LRUCache) moveTail() {
-	rm := this.tail.next
-	delete(this.cache, rm.key)
-	this.removeNode(rm)
+	return LRUCache{cache: New[int, int](capacity)}
 }
 
+// Get returns the value for key or -1 on a miss
 func (this *LRUCache) Get(key int) int {
-	if node, ok := this.cache[key]; ok {
-		this.moveToHead(node)
-		return node.val
+	if this.cache == nil {
+		return -1
+	}
+	if val, ok := this.cache.Get(key); ok {
+		return val
 	}
 	return -1
 }
 
 func (this *LRUCache) Put(key int, value int) {
-	if node, ok := this.cache[key]; ok {
-		node.val = value
-		this.moveToHead(node)
-		return
+	if this.cache != nil {
+		this.cache.Put(key, value)
 	}
-	node := &dll{key, value, nil, nil}
-	this.cache[key] = node
-	if this.capacity > 0 {
-		this.addNode(node)
-		this.capacity--
-		return
+}
+
+func (this *LRUCache) Len() int {
+	if this.cache == nil {
+		return 0
 	}
-	this.addNode(node)
-	this.moveTail()
+	return this.cache.Len()
 }
@@ -0,0 +1,18 @@
+package lru_cache
+
+// Policy is the API shared by every eviction policy in this package (LRU Cache, ARC, TwoQueue, ShardedCache)
+// so they can be swapped and compared on the same workload.
+type Policy[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Peek(key K) (V, bool)
+	Put(key K, value V)
+	Delete(key K) bool
+	Len() int
+}
+
+var (
+	_ Policy[int, int] = (*Cache[int, int])(nil)
+	_ Policy[int, int] = (*ARC[int, int])(nil)
+	_ Policy[int, int] = (*TwoQueue[int, int])(nil)
+	_ Policy[int, int] = (*ShardedCache[int, int])(nil)
+)
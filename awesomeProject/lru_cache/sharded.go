@@ -0,0 +1,159 @@
+package lru_cache
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedCache is a concurrent LRU split into independently locked shards by key hash.
+// Hits never take the write lock: the shard is read under RLock and the recency update is
+// recorded in a small lossy ring buffer (like Caffeine's read buffers). The buffer is applied
+// to the LRU list in a batch when it fills (if the write lock is free) and before every write.
+// Under heavy contention some recency updates are dropped, so eviction order is approximate LRU.
+type ShardedCache[K comparable, V any] struct {
+	seed   maphash.Seed
+	mask   uint64
+	shards []*shard[K, V]
+}
+
+// readBufferSize is the number of hits buffered per shard before a drain is attempted
+const readBufferSize = 64
+
+type shard[K comparable, V any] struct {
+	mu       sync.RWMutex
+	list     *linkedList[K, V]
+	items    map[K]*dll[K, V]
+	capacity int
+	buf      readBuffer[K, V]
+}
+
+type readBuffer[K comparable, V any] struct {
+	idx   atomic.Uint32
+	slots [readBufferSize]atomic.Pointer[dll[K, V]]
+}
+
+// NewSharded creates a sharded LRU holding about capacity entries in total.
+// shards is rounded up to a power of two; 0 picks one based on GOMAXPROCS.
+func NewSharded[K comparable, V any](capacity, shards int) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	perShard := 0
+	if capacity > 0 {
+		perShard = max(1, (capacity+n-1)/n)
+	}
+	c := &ShardedCache[K, V]{seed: maphash.MakeSeed(), mask: uint64(n - 1), shards: make([]*shard[K, V], n)}
+	for i := range c.shards {
+		c.shards[i] = &shard[K, V]{list: newLinkedList[K, V](), items: make(map[K]*dll[K, V]), capacity: perShard}
+	}
+	return c
+}
+
+func (c *ShardedCache[K, V]) shardFor(key K) *shard[K, V] {
+	return c.shards[maphash.Comparable(c.seed, key)&c.mask]
+}
+
+// Get returns the value for key, recording the hit for a later recency update
+func (c *ShardedCache[K, V]) Get(key K) (V, bool) {
+	s := c.shardFor(key)
+	s.mu.RLock()
+	node, ok := s.items[key]
+	var val V
+	if ok {
+		val = node.val
+	}
+	s.mu.RUnlock()
+
+	if ok && s.buf.record(node) && s.mu.TryLock() {
+		s.drain()
+		s.mu.Unlock()
+	}
+	return val, ok
+}
+
+// Peek returns the value for key without recording a hit
+func (c *ShardedCache[K, V]) Peek(key K) (V, bool) {
+	s := c.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if node, ok := s.items[key]; ok {
+		return node.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *ShardedCache[K, V]) Put(key K, value V) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// apply pending hits first so eviction sees current recency
+	s.drain()
+	if node, ok := s.items[key]; ok {
+		node.val = value
+		s.list.moveToHead(node)
+		return
+	}
+	node := &dll[K, V]{key: key, val: value}
+	s.items[key] = node
+	s.list.addNode(node)
+	for s.capacity > 0 && s.list.size > s.capacity {
+		rm := s.list.removeTail()
+		delete(s.items, rm.key)
+	}
+}
+
+func (c *ShardedCache[K, V]) Delete(key K) bool {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	delete(s.items, key)
+	s.list.removeNode(node)
+	return true
+}
+
+// Len returns the total number of entries across shards
+func (c *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.items)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// record adds node to the buffer and reports whether the buffer is full and should be drained.
+// When full, the hit is dropped.
+func (b *readBuffer[K, V]) record(node *dll[K, V]) bool {
+	i := b.idx.Add(1) - 1
+	if i >= readBufferSize {
+		return true
+	}
+	b.slots[i].Store(node)
+	return i == readBufferSize-1
+}
+
+// drain replays buffered hits onto the LRU list. Caller must hold s.mu for writing.
+func (s *shard[K, V]) drain() {
+	n := min(s.buf.idx.Load(), readBufferSize)
+	for i := uint32(0); i < n; i++ {
+		node := s.buf.slots[i].Swap(nil)
+		// skip entries deleted or evicted since the hit was recorded
+		if node != nil && s.items[node.key] == node {
+			s.list.moveToHead(node)
+		}
+	}
+	s.buf.idx.Store(0)
+}
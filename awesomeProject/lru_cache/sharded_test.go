@@ -0,0 +1,128 @@
+package lru_cache
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestShardedBasic(t *testing.T) {
+	c := NewSharded[string, int](16, 1)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v", v, ok)
+	}
+	c.Put("a", 3)
+	if v, _ := c.Peek("a"); v != 3 {
+		t.Fatalf("Peek(a) = %d after update", v)
+	}
+	if !c.Delete("a") || c.Delete("a") {
+		t.Fatal("Delete(a) should succeed once")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("deleted key still present")
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestShardedEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewSharded[int, int](3, 1)
+	c.Put(1, 1)
+	c.Put(2, 2)
+	c.Put(3, 3)
+	c.Get(1) // buffered hit, applied before the next write
+	c.Put(4, 4)
+	if _, ok := c.Peek(2); ok {
+		t.Fatal("2 was least recently used and should be evicted")
+	}
+	for _, k := range []int{1, 3, 4} {
+		if _, ok := c.Peek(k); !ok {
+			t.Fatalf("%d should still be cached", k)
+		}
+	}
+}
+
+// TestShardedConcurrent is meant to run with -race
+func TestShardedConcurrent(t *testing.T) {
+	const capacity, keySpace = 256, 1024
+	c := NewSharded[int, int](capacity, 8)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 5000; i++ {
+				k := rng.Intn(keySpace)
+				switch op := rng.Intn(10); {
+				case op < 6:
+					if v, ok := c.Get(k); ok && v != k {
+						t.Errorf("Get(%d) = %d", k, v)
+						return
+					}
+				case op < 9:
+					c.Put(k, k)
+				default:
+					c.Delete(k)
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	// each of the 8 shards holds at most ceil(256/8) entries
+	if n := c.Len(); n > capacity {
+		t.Fatalf("Len = %d exceeds capacity %d", n, capacity)
+	}
+}
+
+func benchmarkParallel(b *testing.B, newCache func() Policy[int, int], writePct int) {
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			const keySpace = 1 << 16
+			c := newCache()
+			for k := 0; k < keySpace/2; k++ {
+				c.Put(k, k)
+			}
+			// exactly goroutines workers share the b.N operations
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				ops := b.N / goroutines
+				if g < b.N%goroutines {
+					ops++
+				}
+				wg.Add(1)
+				go func(seed int64, ops int) {
+					defer wg.Done()
+					rng := rand.New(rand.NewSource(seed))
+					for i := 0; i < ops; i++ {
+						k := rng.Intn(keySpace)
+						if rng.Intn(100) < writePct {
+							c.Put(k, k)
+						} else {
+							c.Get(k)
+						}
+					}
+				}(rand.Int63(), ops)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkShardedReadHeavy(b *testing.B) {
+	benchmarkParallel(b, func() Policy[int, int] { return NewSharded[int, int](1<<15, 0) }, 10)
+}
+
+func BenchmarkShardedWriteHeavy(b *testing.B) {
+	benchmarkParallel(b, func() Policy[int, int] { return NewSharded[int, int](1<<15, 0) }, 50)
+}
+
+// BenchmarkLockedReadHeavy is the single-lock Cache baseline for BenchmarkShardedReadHeavy
+func BenchmarkLockedReadHeavy(b *testing.B) {
+	benchmarkParallel(b, func() Policy[int, int] { return New[int, int](1 << 15) }, 10)
+}
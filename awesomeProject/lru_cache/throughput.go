@@ -0,0 +1,58 @@
+package lru_cache
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ThroughputResult is the outcome of one MeasureThroughput run
+type ThroughputResult struct {
+	Goroutines int
+	Ops        int64
+	Elapsed    time.Duration
+}
+
+func (r ThroughputResult) OpsPerSec() float64 {
+	return float64(r.Ops) / r.Elapsed.Seconds()
+}
+
+// MeasureThroughput hammers cache from the given number of goroutines for d.
+// Keys are drawn uniformly from [0, keySpace); writePct percent of operations are Puts,
+// the rest Gets. The cache is prefilled so reads mostly hit.
+func MeasureThroughput(cache Policy[int, int], goroutines, keySpace, writePct int, d time.Duration) ThroughputResult {
+	for k := 0; k < keySpace; k++ {
+		cache.Put(k, k)
+	}
+
+	var ops atomic.Int64
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			n := int64(0)
+			for !stop.Load() {
+				// batch between stop checks to keep the flag off the hot path
+				for i := 0; i < 256; i++ {
+					k := r.Intn(keySpace)
+					if r.Intn(100) < writePct {
+						cache.Put(k, k)
+					} else {
+						cache.Get(k)
+					}
+				}
+				n += 256
+			}
+			ops.Add(n)
+		}(int64(g))
+	}
+	time.Sleep(d)
+	stop.Store(true)
+	wg.Wait()
+	return ThroughputResult{Goroutines: goroutines, Ops: ops.Load(), Elapsed: time.Since(start)}
+}
@@ -0,0 +1,245 @@
+package lru_cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Trace replay harness for comparing eviction policies on recorded workloads.
+// Every access is a Get; a miss is followed by a Put, like a read-through cache.
+
+// Access is a single request in a trace. Size is the object size in bytes (1 if unknown)
+// and is only used for byte hit ratio, capacities are in entries.
+type Access struct {
+	Key  string
+	Size int
+}
+
+// Trace is a recorded sequence of key accesses
+type Trace []Access
+
+// Supported trace formats
+const (
+	// FormatPlain is one access per line, "key[,size]". Blank lines and '#' comments are skipped.
+	FormatPlain = "plain"
+	// FormatARC is the block trace format used by the ARC paper (*.lis):
+	// "startBlock numBlocks ignored requestNo", expanded to numBlocks sequential accesses.
+	FormatARC = "arc"
+	// FormatTwitter is the Twitter cache-trace CSV:
+	// "timestamp,key,keySize,valueSize,clientId,operation,ttl". Only get/gets are replayed.
+	FormatTwitter = "twitter"
+)
+
+// ReadTrace parses a trace in FormatPlain
+func ReadTrace(r io.Reader) (Trace, error) {
+	return ReadTraceFormat(r, FormatPlain)
+}
+
+// ReadTraceFormat parses a trace in one of the supported formats
+func ReadTraceFormat(r io.Reader, format string) (Trace, error) {
+	var parse func(line string, trace Trace) (Trace, error)
+	switch format {
+	case FormatPlain, "", "csv":
+		parse = parsePlainLine
+	case FormatARC:
+		parse = parseARCLine
+	case FormatTwitter:
+		parse = parseTwitterLine
+	default:
+		return nil, fmt.Errorf("unknown trace format: %s", format)
+	}
+
+	var trace Trace
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var err error
+		if trace, err = parse(line, trace); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func LoadTrace(path string) (Trace, error) {
+	return LoadTraceFormat(path, FormatPlain)
+}
+
+func LoadTraceFormat(path, format string) (Trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTraceFormat(f, format)
+}
+
+func parsePlainLine(line string, trace Trace) (Trace, error) {
+	fields := strings.Split(line, ",")
+	acc := Access{Key: strings.TrimSpace(fields[0]), Size: 1}
+	if len(fields) > 1 {
+		size, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			// tolerate a header row
+			if len(trace) == 0 {
+				return trace, nil
+			}
+			return nil, fmt.Errorf("bad size %q", fields[1])
+		}
+		acc.Size = size
+	}
+	return append(trace, acc), nil
+}
+
+func parseARCLine(line string, trace Trace) (Trace, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("expected at least 2 fields, got %d", len(fields))
+	}
+	start, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad start block %q", fields[0])
+	}
+	n, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad block count %q", fields[1])
+	}
+	for b := start; b < start+n; b++ {
+		trace = append(trace, Access{Key: strconv.FormatInt(b, 10), Size: 1})
+	}
+	return trace, nil
+}
+
+func parseTwitterLine(line string, trace Trace) (Trace, error) {
+	fields := strings.Split(line, ",")
+	// timestamp, key, key size, value size, client id, operation, TTL
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+	if op := fields[5]; op != "get" && op != "gets" {
+		return trace, nil
+	}
+	keySize, err1 := strconv.Atoi(fields[2])
+	valueSize, err2 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("bad key/value size %q/%q", fields[2], fields[3])
+	}
+	return append(trace, Access{Key: fields[1], Size: keySize + valueSize}), nil
+}
+
+// ReplayCache is the subset of Policy the harness needs, so adapters for caches outside this
+// package only implement what replay uses
+type ReplayCache interface {
+	Get(key string) (struct{}, bool)
+	Put(key string, value struct{})
+	Len() int
+}
+
+// PolicyFactory builds an empty cache of a given capacity for replay
+type PolicyFactory struct {
+	Name string
+	New  func(capacity int) ReplayCache
+}
+
+// DefaultPolicies returns LRU, ARC and 2Q
+func DefaultPolicies() []PolicyFactory {
+	return []PolicyFactory{
+		{Name: "LRU", New: func(capacity int) ReplayCache { return New[string, struct{}](capacity) }},
+		{Name: "ARC", New: func(capacity int) ReplayCache { return NewARC[string, struct{}](capacity) }},
+		{Name: "2Q", New: func(capacity int) ReplayCache { return NewTwoQueue[string, struct{}](capacity) }},
+	}
+}
+
+type ReplayResult struct {
+	Policy    string
+	Capacity  int
+	Hits      int
+	Misses    int
+	HitBytes  int64
+	MissBytes int64
+	Evictions int
+}
+
+func (r ReplayResult) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+func (r ReplayResult) ByteHitRatio() float64 {
+	if r.HitBytes+r.MissBytes == 0 {
+		return 0
+	}
+	return float64(r.HitBytes) / float64(r.HitBytes+r.MissBytes)
+}
+
+// Replay runs trace through a fresh cache built by f
+func Replay(f PolicyFactory, capacity int, trace Trace) ReplayResult {
+	cache := f.New(capacity)
+	res := ReplayResult{Policy: f.Name, Capacity: capacity}
+	for _, acc := range trace {
+		if _, ok := cache.Get(acc.Key); ok {
+			res.Hits++
+			res.HitBytes += int64(acc.Size)
+			continue
+		}
+		res.Misses++
+		res.MissBytes += int64(acc.Size)
+		cache.Put(acc.Key, struct{}{})
+	}
+	// every miss inserts exactly one key and replay never deletes,
+	// so whatever is not resident at the end was evicted
+	res.Evictions = res.Misses - cache.Len()
+	return res
+}
+
+// CompareHitRatios replays trace through every default policy at the given capacity
+func CompareHitRatios(trace Trace, capacity int) []ReplayResult {
+	return Sweep(DefaultPolicies(), []int{capacity}, trace)
+}
+
+// Sweep replays trace through every policy at every capacity, grouped by policy
+func Sweep(policies []PolicyFactory, capacities []int, trace Trace) []ReplayResult {
+	results := make([]ReplayResult, 0, len(policies)*len(capacities))
+	for _, f := range policies {
+		for _, capacity := range capacities {
+			results = append(results, Replay(f, capacity, trace))
+		}
+	}
+	return results
+}
+
+// PrintResults writes results as an aligned table
+func PrintResults(w io.Writer, results []ReplayResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "policy\tcapacity\thits\tmisses\thit ratio\tbyte hit ratio\tevictions")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.4f\t%.4f\t%d\n",
+			r.Policy, r.Capacity, r.Hits, r.Misses, r.HitRatio(), r.ByteHitRatio(), r.Evictions)
+	}
+	tw.Flush()
+}
+
+// PrintResultsCSV writes results as CSV for plotting hit-ratio curves
+func PrintResultsCSV(w io.Writer, results []ReplayResult) {
+	fmt.Fprintln(w, "policy,capacity,hits,misses,hit_ratio,byte_hit_ratio,evictions")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s,%d,%d,%d,%.6f,%.6f,%d\n",
+			r.Policy, r.Capacity, r.Hits, r.Misses, r.HitRatio(), r.ByteHitRatio(), r.Evictions)
+	}
+}
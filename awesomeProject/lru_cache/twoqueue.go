@@ -0,0 +1,138 @@
+package lru_cache
+
+import "sync"
+
+// default 2Q tuning from the paper: Kin = 25% and Kout = 50% of capacity
+const (
+	DefaultTwoQueueRecentRatio = 0.25
+	DefaultTwoQueueGhostRatio  = 0.50
+)
+
+// TwoQueue is the full 2Q algorithm (Johnson & Shasha, VLDB '94).
+// - a1in is a FIFO of entries seen once; hits there do not promote
+// - a1out is a FIFO of ghost keys recently evicted from a1in
+// - am is an LRU of entries referenced again after falling out of a1in
+// Scans pass through a1in/a1out without displacing the hot set in am.
+type TwoQueue[K comparable, V any] struct {
+	mu        sync.Mutex
+	capacity  int
+	recentCap int // Kin
+	ghostCap  int // Kout
+	a1in      *indexedList[K, V]
+	a1out     *indexedList[K, V]
+	am        *indexedList[K, V]
+}
+
+func NewTwoQueue[K comparable, V any](capacity int) *TwoQueue[K, V] {
+	return NewTwoQueueParams[K, V](capacity, DefaultTwoQueueRecentRatio, DefaultTwoQueueGhostRatio)
+}
+
+// NewTwoQueueParams lets callers tune the a1in and a1out sizes as fractions of capacity
+func NewTwoQueueParams[K comparable, V any](capacity int, recentRatio, ghostRatio float64) *TwoQueue[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TwoQueue[K, V]{
+		capacity:  capacity,
+		recentCap: max(1, int(float64(capacity)*recentRatio)),
+		ghostCap:  max(1, int(float64(capacity)*ghostRatio)),
+		a1in:      newIndexedList[K, V](),
+		a1out:     newIndexedList[K, V](),
+		am:        newIndexedList[K, V](),
+	}
+}
+
+func (c *TwoQueue[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.am.index[key]; ok {
+		c.am.moveToHead(node)
+		return node.val, true
+	}
+	// a1in is FIFO: a hit leaves the entry where it is
+	if node, ok := c.a1in.index[key]; ok {
+		return node.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *TwoQueue[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.am.index[key]; ok {
+		return node.val, true
+	}
+	if node, ok := c.a1in.index[key]; ok {
+		return node.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *TwoQueue[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.am.index[key]; ok {
+		node.val = value
+		c.am.moveToHead(node)
+		return
+	}
+	if node, ok := c.a1in.index[key]; ok {
+		node.val = value
+		return
+	}
+	// seen recently and evicted from a1in: it is hot, go straight to am
+	if node, ok := c.a1out.index[key]; ok {
+		c.a1out.unlink(node)
+		c.reclaim()
+		c.am.pushFront(key, value)
+		return
+	}
+	c.reclaim()
+	c.a1in.pushFront(key, value)
+}
+
+func (c *TwoQueue[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if node, ok := c.am.index[key]; ok {
+		c.am.unlink(node)
+		return true
+	}
+	if node, ok := c.a1in.index[key]; ok {
+		c.a1in.unlink(node)
+		return true
+	}
+	if node, ok := c.a1out.index[key]; ok {
+		c.a1out.unlink(node)
+	}
+	return false
+}
+
+// Len returns the number of resident entries
+func (c *TwoQueue[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.a1in.size + c.am.size
+}
+
+// reclaim frees one resident slot if the cache is full
+func (c *TwoQueue[K, V]) reclaim() {
+	if c.a1in.size+c.am.size < c.capacity {
+		return
+	}
+	if c.a1in.size > c.recentCap || c.am.size == 0 {
+		node := c.a1in.popTail()
+		c.a1out.pushFront(node.key, *new(V))
+		if c.a1out.size > c.ghostCap {
+			c.a1out.popTail()
+		}
+		return
+	}
+	c.am.popTail()
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"awesomeProject/lru_cache"
+	"fmt"
+	"os"
+)
+
+// cache_bench replays a recorded trace (one key per line) through LRU, ARC and 2Q
+func cache_bench(tracePath string, capacity int) {
+	trace, err := lru_cache.LoadTrace(tracePath)
+	if err != nil {
+		fmt.Printf("failed to load trace: %v\n", err)
+		return
+	}
+	fmt.Printf("replaying %d accesses from %s\n", len(trace), tracePath)
+	lru_cache.PrintResults(os.Stdout, lru_cache.CompareHitRatios(trace, capacity))
+}
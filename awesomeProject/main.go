@@ -6,4 +6,5 @@ func main() {
 	//vending_machine_rack()
 	snake_n_ladder()
 	//in_memory_db.In_mem_db()
+	//cache_bench("trace.txt", 1000)
 }
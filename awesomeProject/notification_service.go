@@ -18,7 +18,7 @@ func notification_service() {
 	noti := &ns.Notification{
 		ID:       "notif_" + strconv.Itoa(rand.Intn(1000000)),
 		Title:    "Welcome to our service",
-		Body:     "Hello {{name}}, thank you for joining us!",
+		Body:     "Hello {{.name}}, thank you for joining us!",
 		Channels: []ns.Channel{ns.ChannelEmail, ns.ChannelSMS},
 		Data:     map[string]interface{}{"name": "John"},
 		Recipients: []ns.Recipient{
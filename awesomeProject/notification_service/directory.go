@@ -0,0 +1,164 @@
+package notification_service
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// --- Recipient directory ---
+
+// Contact holds the addresses a user can be reached at
+type Contact struct {
+	RecipientID  string   `json:"recipientId"`
+	Email        string   `json:"email,omitempty"`
+	Phone        string   `json:"phone,omitempty"` // E.164, e.g. "+14155550100"
+	DeviceTokens []string `json:"deviceTokens,omitempty"`
+	WebhookURL   string   `json:"webhookUrl,omitempty"`
+	Locale       string   `json:"locale,omitempty"` // for templates, e.g. "pt-BR"
+}
+
+// Address returns the contact's address for ch. Push goes to the most recently added device token.
+func (c Contact) Address(ch Channel) (string, bool) {
+	var addr string
+	switch ch {
+	case ChannelEmail:
+		addr = c.Email
+	case ChannelSMS:
+		addr = c.Phone
+	case ChannelPush:
+		if len(c.DeviceTokens) > 0 {
+			addr = c.DeviceTokens[len(c.DeviceTokens)-1]
+		}
+	case ChannelWebhook:
+		addr = c.WebhookURL
+	}
+	return addr, addr != ""
+}
+
+// Validate checks every address the contact has
+func (c Contact) Validate() error {
+	if c.RecipientID == "" {
+		return errors.New("contact has no recipient id")
+	}
+	addrs := map[Channel]string{ChannelEmail: c.Email, ChannelSMS: c.Phone, ChannelWebhook: c.WebhookURL}
+	for _, ch := range []Channel{ChannelEmail, ChannelSMS, ChannelWebhook} {
+		if addrs[ch] == "" {
+			continue
+		}
+		if err := ValidateAddress(ch, addrs[ch]); err != nil {
+			return fmt.Errorf("contact %s: %w", c.RecipientID, err)
+		}
+	}
+	for _, t := range c.DeviceTokens {
+		if err := ValidateAddress(ChannelPush, t); err != nil {
+			return fmt.Errorf("contact %s: %w", c.RecipientID, err)
+		}
+	}
+	return nil
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// ValidateAddress checks that addr is a well-formed address for ch: a bare email address,
+// an E.164 phone number, a device token without whitespace, or an absolute http(s) URL
+func ValidateAddress(ch Channel, addr string) error {
+	switch ch {
+	case ChannelEmail:
+		a, err := mail.ParseAddress(addr)
+		if err != nil || a.Address != addr {
+			return fmt.Errorf("invalid email address %q", addr)
+		}
+	case ChannelSMS:
+		if !e164.MatchString(addr) {
+			return fmt.Errorf("invalid phone number %q, want E.164 like +14155550100", addr)
+		}
+	case ChannelPush:
+		if addr == "" || len(addr) > 4096 || strings.ContainsAny(addr, " \t\r\n") {
+			return fmt.Errorf("invalid device token %q", addr)
+		}
+	case ChannelWebhook:
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url %q", addr)
+		}
+	default:
+		return fmt.Errorf("unknown channel %q, want one of %s, %s, %s or %s",
+			ch, ChannelEmail, ChannelSMS, ChannelPush, ChannelWebhook)
+	}
+	return nil
+}
+
+// normalizeAddresses validates the inline addresses of a recipient and returns them keyed by
+// upper-case channel, so "email" and "EMAIL" both mean ChannelEmail
+func normalizeAddresses(addrs map[string]string) (map[string]string, error) {
+	if len(addrs) == 0 {
+		return addrs, nil
+	}
+	out := make(map[string]string, len(addrs))
+	for key, addr := range addrs {
+		ch := Channel(strings.ToUpper(key))
+		if _, dup := out[string(ch)]; dup {
+			return nil, fmt.Errorf("two addresses for channel %s", ch)
+		}
+		if err := ValidateAddress(ch, addr); err != nil {
+			return nil, err
+		}
+		out[string(ch)] = addr
+	}
+	return out, nil
+}
+
+type InMemoryDirectory struct {
+	mu       sync.RWMutex
+	contacts map[string]Contact
+}
+
+func NewInMemoryDirectory() *InMemoryDirectory {
+	return &InMemoryDirectory{contacts: make(map[string]Contact)}
+}
+
+// Upsert validates c and stores it, replacing the recipient's previous contact
+func (d *InMemoryDirectory) Upsert(c Contact) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c.DeviceTokens = append([]string(nil), c.DeviceTokens...)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.contacts[c.RecipientID] = c
+	return nil
+}
+
+func (d *InMemoryDirectory) Get(recipientID string) (Contact, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	c, ok := d.contacts[recipientID]
+	return c, ok
+}
+
+func (d *InMemoryDirectory) Delete(recipientID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.contacts, recipientID)
+}
+
+// resolveAddress returns where to send ch to r: an address given on the notification's
+// recipient wins over the directory. Channel keys of inline addresses match in any case.
+func (a *App) resolveAddress(r Recipient, ch Channel) (string, error) {
+	for key, addr := range r.Addresses {
+		if addr != "" && strings.EqualFold(key, string(ch)) {
+			return addr, nil
+		}
+	}
+	if c, ok := a.directory.Get(r.ID); ok {
+		if addr, ok := c.Address(ch); ok {
+			return addr, nil
+		}
+	}
+	return "", fmt.Errorf("recipient %s has no %s address", r.ID, ch)
+}
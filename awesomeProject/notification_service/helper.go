@@ -1,34 +1,34 @@
 package notification_service
 
-import (
-	"context"
-)
+// --- Renderer ---
 
-// --- Simple renderer & provider mocks ---
-
-func render(n *Notification, r Recipient, ch Channel) (string, string) {
-	subject := n.Title
-	body := n.Body
-	// naive template: replace {{name}} if provided
-	//if name, ok := n.Data["name"].(string); ok {
-	//	body = replace(body, "{{name}}", name)
-	//}
-	return subject, body
+// render renders n for r on ch: with the stored template n.Template in the recipient's locale,
+// or else with n.Title and n.Body as inline text templates. Without Data they are sent as
+// written, so plain text containing "{{" keeps working.
+func (a *App) render(n *Notification, r Recipient, ch Channel) (Rendered, error) {
+	var ct *compiledTemplate
+	var err error
+	switch {
+	case n.Template != "":
+		ct, err = a.templates.find(n.Template, ch, a.localeFor(r), n.TemplateVersion)
+	case len(n.Data) == 0:
+		return Rendered{Subject: n.Title, Body: n.Body}, nil
+	default:
+		ct, err = compileTemplate(Template{Subject: n.Title, Body: n.Body})
+	}
+	if err != nil {
+		return Rendered{}, err
+	}
+	return ct.render(n.Data)
 }
 
-// provider simulates success/failure
-func sendToProvider(ctx context.Context, ch Channel, subject, body string) (string, error) {
-	// simulate latency and random failures
-	return "sent", nil
-	//select {
-	//case <-ctx.Done():
-	//	return "", ctx.Err()
-	//case <-time.After(time.Duration(100+rand.Intn(200)) * time.Millisecond):
-	//}
-	//if rand.Float32() < 0.85 {
-	//	return fmt.Sprintf("prov_%d", rand.Int63()), nil
-	//}
-	//return "", errors.New("provider error")
+// localeFor returns the recipient's locale, from the notification or else the directory
+func (a *App) localeFor(r Recipient) string {
+	if r.Locale != "" {
+		return r.Locale
+	}
+	c, _ := a.directory.Get(r.ID)
+	return c.Locale
 }
 
 func findRecipient(n *Notification, id string) Recipient {
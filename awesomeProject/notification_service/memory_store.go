@@ -98,6 +98,8 @@ func (s *InMemoryStore) UpdateAttempt(a *DeliveryAttempt) {
 		existing.Status = a.Status
 		existing.AttemptNo = a.AttemptNo
 		existing.NextAttemptAt = a.NextAttemptAt
+		existing.ProviderMessageID = a.ProviderMessageID
+		existing.Error = a.Error
 		existing.UpdatedAt = time.Now().UTC()
 	}
 }
@@ -9,20 +9,25 @@ type OutboxEvent struct {
 	CreatedAt      time.Time
 }
 
+// Notification goes to every recipient on every channel. Without a Template, Title and Body are
+// text/templates executed with Data, e.g. "Hi {{.name}}"; without Data they are sent as written.
 type Notification struct {
-	ID         string                 `json:"id"`
-	Title      string                 `json:"title,omitempty"`
-	Body       string                 `json:"body,omitempty"`
-	Channels   []Channel              `json:"channels"`
-	Data       map[string]interface{} `json:"data,omitempty"`
-	Recipients []Recipient            `json:"recipients,omitempty"`
-	Status     NotificationStatus     `json:"status"`
-	CreatedAt  time.Time              `json:"createdAt"`
+	ID              string                 `json:"id"`
+	Title           string                 `json:"title,omitempty"`
+	Body            string                 `json:"body,omitempty"`
+	Template        string                 `json:"template,omitempty"`        // stored template used instead of Title and Body
+	TemplateVersion int                    `json:"templateVersion,omitempty"` // pins a version in the resolved locale, 0 uses the latest
+	Channels        []Channel              `json:"channels"`
+	Data            map[string]interface{} `json:"data,omitempty"`
+	Recipients      []Recipient            `json:"recipients,omitempty"`
+	Status          NotificationStatus     `json:"status"`
+	CreatedAt       time.Time              `json:"createdAt"`
 }
 
 type Recipient struct {
-	ID string `json:"id"`
-	//Addresses map[string]string `json:"addresses,omitempty"`
+	ID        string            `json:"id"`
+	Addresses map[string]string `json:"addresses,omitempty"` // channel -> address, overrides the directory
+	Locale    string            `json:"locale,omitempty"`    // overrides the directory
 }
 
 type DeliveryAttempt struct {
@@ -34,4 +39,7 @@ type DeliveryAttempt struct {
 	AttemptNo      int           `json:"attemptNo"`
 	NextAttemptAt  time.Time     `json:"nextAttemptAt,omitempty"`
 	UpdatedAt      time.Time     `json:"updatedAt"`
+
+	ProviderMessageID string `json:"providerMessageId,omitempty"` // set once delivered
+	Error             string `json:"error,omitempty"`             // why the last try failed
 }
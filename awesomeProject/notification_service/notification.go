@@ -13,8 +13,10 @@ import (
 type Channel string
 
 const (
-	ChannelEmail Channel = "EMAIL"
-	ChannelSMS   Channel = "SMS"
+	ChannelEmail   Channel = "EMAIL"
+	ChannelSMS     Channel = "SMS"
+	ChannelPush    Channel = "PUSH"
+	ChannelWebhook Channel = "WEBHOOK"
 )
 
 type NotificationStatus string
@@ -42,19 +44,55 @@ const (
 // --- App ---
 
 type App struct {
-	store    *InMemoryStore
-	outbox   chan OutboxEvent // dont use
-	shutdown chan struct{}
+	store     *InMemoryStore
+	outbox    chan OutboxEvent // dont use
+	shutdown  chan struct{}
+	providers map[Channel]Provider
+	directory *InMemoryDirectory
+	templates *InMemoryTemplateStore
 }
 
-func NewApp() *App {
-	return &App{
-		store:    NewInMemoryStore(),
-		outbox:   make(chan OutboxEvent, 1000),
-		shutdown: make(chan struct{}),
+// AppOption configures an App
+type AppOption func(*App)
+
+// WithProvider registers the provider delivering its channel; a later provider for the same
+// channel replaces an earlier one
+func WithProvider(p Provider) AppOption {
+	return func(a *App) {
+		a.providers[p.Channel()] = p
 	}
 }
 
+// WithDirectory sets the recipient directory addresses are resolved from, e.g. to share one
+// between apps; by default each App has its own
+func WithDirectory(d *InMemoryDirectory) AppOption {
+	return func(a *App) {
+		a.directory = d
+	}
+}
+
+// WithTemplates sets the template store; by default each App has its own with locale "en"
+func WithTemplates(s *InMemoryTemplateStore) AppOption {
+	return func(a *App) {
+		a.templates = s
+	}
+}
+
+func NewApp(options ...AppOption) *App {
+	a := &App{
+		store:     NewInMemoryStore(),
+		outbox:    make(chan OutboxEvent, 1000),
+		shutdown:  make(chan struct{}),
+		providers: make(map[Channel]Provider),
+		directory: NewInMemoryDirectory(),
+		templates: NewInMemoryTemplateStore("en"),
+	}
+	for _, opt := range options {
+		opt(a)
+	}
+	return a
+}
+
 // create notification
 func (a *App) CreateNotification(noti *Notification) (id string, status string, err error) {
 	if noti.ID == "" {
@@ -63,6 +101,13 @@ func (a *App) CreateNotification(noti *Notification) (id string, status string,
 	if len(noti.Channels) == 0 {
 		noti.Channels = []Channel{ChannelEmail}
 	}
+	for i, rc := range noti.Recipients {
+		addrs, err := normalizeAddresses(rc.Addresses)
+		if err != nil {
+			return "", "", fmt.Errorf("recipient %s: %w", rc.ID, err)
+		}
+		noti.Recipients[i].Addresses = addrs
+	}
 	noti.Status = StatusQueued
 	a.store.CreateNotification(noti)
 	for _, rc := range noti.Recipients {
@@ -84,6 +129,22 @@ func (a *App) CreateNotification(noti *Notification) (id string, status string,
 	return noti.ID, string(noti.Status), nil
 }
 
+// add or replace a recipient's contact addresses in the directory
+func (a *App) UpsertContact(c Contact) error {
+	return a.directory.Upsert(c)
+}
+
+// store a new version of a template, returning its version number
+func (a *App) SaveTemplate(t Template) (int, error) {
+	return a.templates.Save(t)
+}
+
+// Preview renders n for a recipient and channel without storing or sending anything,
+// e.g. to check a draft or a new template version
+func (a *App) Preview(n *Notification, recipientID string, ch Channel) (Rendered, error) {
+	return a.render(n, findRecipient(n, recipientID), ch)
+}
+
 // list notifications
 func (a *App) ListNotifications() []*Notification {
 	return a.store.ListNotifications()
@@ -116,8 +177,6 @@ func (a *App) SendNotification(id string) error {
 	return nil
 }
 
-
-
 // worker loop: poll pending attempts and send
 func (a *App) SenderWorker(ctx context.Context, id int) {
 	log.Printf("senderWorker %d started", id)
@@ -129,48 +188,73 @@ func (a *App) SenderWorker(ctx context.Context, id int) {
 			log.Printf("senderWorker %d stopping", id)
 			return
 		case <-ticker.C:
-			atts := a.store.NextPendingAttempts(10)
-			for _, att := range atts {
-				// quick state flip
-				att.Status = AttemptDelivering
-				a.store.UpdateAttempt(att)
-				// load notification + recipient
-				n, ok := a.store.GetNotification(att.NotificationID)
-				if !ok {
-					att.Status = AttemptFailed
-					a.store.UpdateAttempt(att)
-					continue
-				}
-				r := findRecipient(n, att.RecipientID)
-				subj, body := render(n, r, att.Channel)
-				// choose address
-				//addr := ""
-				//if r.Addresses != nil {
-				//	if v, ok := r.Addresses[string(att.Channel)]; ok {
-				//		addr = v
-				//	}
-				//}
-				//if addr == "" {
-				//	att.Status = AttemptFailed
-				//	a.store.UpdateAttempt(att)
-				//	continue
-				//}
-				ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
-				_, err := sendToProvider(ctx2, att.Channel, subj, body)
-				cancel()
-				if err != nil {
-					att.AttemptNo++
-					if att.AttemptNo >= 3 {
-						att.Status = AttemptFailed
-					} else {
-						att.Status = AttemptPending
-						att.NextAttemptAt = time.Now().Add(time.Duration(1<<att.AttemptNo) * time.Second)
-					}
-				} else {
-					att.Status = AttemptDelivered
-				}
-				a.store.UpdateAttempt(att)
+			for _, att := range a.store.NextPendingAttempts(10) {
+				a.deliver(ctx, att)
 			}
 		}
 	}
 }
+
+// deliver makes one try of att: it renders the notification, resolves the address and sends it
+// with the channel's provider. Failed sends are retried with backoff up to 3 tries; anything
+// failing before the send fails the attempt for good.
+func (a *App) deliver(ctx context.Context, att *DeliveryAttempt) {
+	// quick state flip
+	att.Status = AttemptDelivering
+	a.store.UpdateAttempt(att)
+	// load notification + recipient
+	n, ok := a.store.GetNotification(att.NotificationID)
+	if !ok {
+		a.failAttempt(att, "notification not found")
+		return
+	}
+	r := findRecipient(n, att.RecipientID)
+	msg, err := a.render(n, r, att.Channel)
+	if err != nil {
+		a.failAttempt(att, "render: "+err.Error())
+		return
+	}
+	// choose address
+	addr, err := a.resolveAddress(r, att.Channel)
+	if err != nil {
+		a.failAttempt(att, err.Error())
+		return
+	}
+	p, ok := a.providers[att.Channel]
+	if !ok {
+		a.failAttempt(att, fmt.Sprintf("no provider for channel %s", att.Channel))
+		return
+	}
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	msgID, err := p.Send(ctx2, Message{
+		NotificationID: n.ID,
+		RecipientID:    r.ID,
+		To:             addr,
+		Subject:        msg.Subject,
+		Body:           msg.Body,
+		HTML:           msg.HTML,
+	})
+	cancel()
+	if err != nil {
+		att.Error = err.Error()
+		att.AttemptNo++
+		if att.AttemptNo >= 3 {
+			att.Status = AttemptFailed
+		} else {
+			att.Status = AttemptPending
+			att.NextAttemptAt = time.Now().Add(time.Duration(1<<att.AttemptNo) * time.Second)
+		}
+	} else {
+		att.Status = AttemptDelivered
+		att.ProviderMessageID = msgID
+		att.Error = ""
+	}
+	a.store.UpdateAttempt(att)
+}
+
+// failAttempt marks att failed for good, recording why
+func (a *App) failAttempt(att *DeliveryAttempt, reason string) {
+	att.Status = AttemptFailed
+	att.Error = reason
+	a.store.UpdateAttempt(att)
+}
@@ -0,0 +1,98 @@
+package notification_service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// recordingProvider delivers every message successfully and keeps it
+type recordingProvider struct {
+	channel Channel
+	sent    []Message
+}
+
+func (p *recordingProvider) Channel() Channel { return p.channel }
+
+func (p *recordingProvider) Send(_ context.Context, msg Message) (string, error) {
+	p.sent = append(p.sent, msg)
+	return "msg-" + msg.RecipientID, nil
+}
+
+// deliverPending makes one try of every pending attempt and returns them by recipient
+func deliverPending(a *App) map[string]*DeliveryAttempt {
+	byRecipient := make(map[string]*DeliveryAttempt)
+	for _, att := range a.store.NextPendingAttempts(100) {
+		a.deliver(context.Background(), att)
+		byRecipient[att.RecipientID] = att
+	}
+	return byRecipient
+}
+
+func TestCreateNotificationNormalizesAddressKeys(t *testing.T) {
+	a := NewApp()
+	n := &Notification{Title: "t", Body: "b", Recipients: []Recipient{{ID: "r1", Addresses: map[string]string{"email": "ana@example.com"}}}}
+	if _, _, err := a.CreateNotification(n); err != nil {
+		t.Fatalf("lower-case channel key: %v", err)
+	}
+	if got := n.Recipients[0].Addresses; got["EMAIL"] != "ana@example.com" || len(got) != 1 {
+		t.Fatalf("addresses stored as %v", got)
+	}
+
+	for name, addrs := range map[string]map[string]string{
+		"unknown channel": {"fax": "+14155550100"},
+		"duplicate":       {"email": "a@example.com", "EMAIL": "b@example.com"},
+		"bad address":     {"Sms": "555-0100"},
+	} {
+		n := &Notification{Title: "t", Body: "b", Recipients: []Recipient{{ID: "r1", Addresses: addrs}}}
+		if _, _, err := a.CreateNotification(n); err == nil {
+			t.Errorf("%s: accepted %v", name, addrs)
+		} else if name == "unknown channel" && !strings.Contains(err.Error(), "EMAIL, SMS, PUSH or WEBHOOK") {
+			t.Errorf("unknown channel error %q does not name the valid channels", err)
+		}
+	}
+}
+
+func TestAddressResolution(t *testing.T) {
+	email := &recordingProvider{channel: ChannelEmail}
+	a := NewApp(WithProvider(email))
+	for _, c := range []Contact{
+		{RecipientID: "inline", Email: "directory1@example.com"},
+		{RecipientID: "directory", Email: "directory2@example.com"},
+		{RecipientID: "phone-only", Phone: "+14155550100"},
+	} {
+		if err := a.UpsertContact(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := &Notification{Title: "t", Body: "b", Channels: []Channel{ChannelEmail}, Recipients: []Recipient{
+		{ID: "inline", Addresses: map[string]string{"email": "inline@example.com"}},
+		{ID: "directory"},
+		{ID: "phone-only"},
+		{ID: "unknown"},
+	}}
+	if _, _, err := a.CreateNotification(n); err != nil {
+		t.Fatal(err)
+	}
+	atts := deliverPending(a)
+
+	to := make(map[string]string)
+	for _, m := range email.sent {
+		to[m.RecipientID] = m.To
+	}
+	if to["inline"] != "inline@example.com" {
+		t.Errorf("inline address should win over the directory, sent to %q", to["inline"])
+	}
+	if to["directory"] != "directory2@example.com" {
+		t.Errorf("directory address not used, sent to %q", to["directory"])
+	}
+	for _, id := range []string{"phone-only", "unknown"} {
+		att := atts[id]
+		if att.Status != AttemptFailed || att.Error != "recipient "+id+" has no EMAIL address" {
+			t.Errorf("%s: attempt %s %q, want failed for lack of an address", id, att.Status, att.Error)
+		}
+	}
+	if len(email.sent) != 2 {
+		t.Errorf("sent %d messages, want 2", len(email.sent))
+	}
+}
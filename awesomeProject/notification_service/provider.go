@@ -0,0 +1,270 @@
+package notification_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// --- Providers ---
+
+// Message is one rendered notification for one recipient address
+type Message struct {
+	NotificationID string
+	RecipientID    string
+	To             string // address on the provider's channel: email, phone number, webhook URL
+	Subject        string
+	Body           string
+	HTML           string // alternative HTML body, for providers supporting it
+}
+
+// Provider delivers messages on one Channel. Send returns the provider's ID for the message,
+// which is stored on the DeliveryAttempt.
+type Provider interface {
+	Channel() Channel
+	Send(ctx context.Context, msg Message) (messageID string, err error)
+}
+
+// newMessageID returns a random ID for providers that do not assign one
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("message id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// --- SMTP email ---
+
+type smtpProvider struct {
+	addr string // host:port
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPProvider sends email through the SMTP server at addr ("host:port") as from.
+// STARTTLS is used when the server offers it; auth may be nil for servers without AUTH.
+// The returned message ID is the Message-ID header of the mail.
+func NewSMTPProvider(addr, from string, auth smtp.Auth) Provider {
+	return &smtpProvider{addr: addr, from: from, auth: auth}
+}
+
+func (p *smtpProvider) Channel() Channel { return ChannelEmail }
+
+func (p *smtpProvider) Send(ctx context.Context, msg Message) (string, error) {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return "", fmt.Errorf("smtp: bad address %q", msg.To)
+	}
+	random, err := newMessageID()
+	if err != nil {
+		return "", fmt.Errorf("smtp: %w", err)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return "", fmt.Errorf("smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(p.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	id := fmt.Sprintf("<%s@%s>", random, host)
+	if err := p.deliver(c, host, msg, id); err != nil {
+		return "", fmt.Errorf("smtp: %w", err)
+	}
+	return id, nil
+}
+
+func (p *smtpProvider) deliver(c *smtp.Client, host string, msg Message, id string) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if p.auth != nil {
+		if err := c.Auth(p.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(p.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMessage-ID: %s\r\n",
+		p.from, msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), time.Now().Format(time.RFC1123Z), id)
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		fmt.Fprintf(w, "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+		io.WriteString(w, msg.Body)
+	} else if err := writeAlternative(w, msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// writeAlternative writes a multipart/alternative body with the plain text and HTML parts
+func writeAlternative(w io.Writer, msg Message) error {
+	mw := multipart.NewWriter(w)
+	fmt.Fprintf(w, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Body},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return err
+		}
+		io.WriteString(pw, part.content)
+	}
+	return mw.Close()
+}
+
+// --- SMS over an HTTP gateway ---
+
+type smsGateway struct {
+	url    string
+	token  string
+	from   string
+	client *http.Client
+}
+
+// NewSMSGatewayProvider sends SMS through an HTTP gateway: each message is POSTed to url as
+// JSON {"from", "to", "body"} with the token as a bearer credential, and the gateway answers
+// 2xx with JSON {"id"}. A nil client means http.DefaultClient.
+func NewSMSGatewayProvider(url, token, from string, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &smsGateway{url: url, token: token, from: from, client: client}
+}
+
+func (p *smsGateway) Channel() Channel { return ChannelSMS }
+
+func (p *smsGateway) Send(ctx context.Context, msg Message) (string, error) {
+	payload, _ := json.Marshal(map[string]string{"from": p.from, "to": msg.To, "body": msg.Body})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("sms gateway: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sms gateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", fmt.Errorf("sms gateway: %w", err)
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.ID == "" {
+		return "", errors.New("sms gateway: response has no message id")
+	}
+	return out.ID, nil
+}
+
+// --- Webhook ---
+
+type webhookProvider struct {
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookProvider POSTs each message as JSON to the recipient's webhook URL. The message ID
+// is generated and sent in the X-Message-ID header so receivers can deduplicate retries. With a
+// secret, the body is signed in X-Signature as "sha256=" + hex(HMAC-SHA256(secret, body)).
+// A nil client means http.DefaultClient.
+func NewWebhookProvider(secret string, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookProvider{secret: []byte(secret), client: client}
+}
+
+func (p *webhookProvider) Channel() Channel { return ChannelWebhook }
+
+func (p *webhookProvider) Send(ctx context.Context, msg Message) (string, error) {
+	id, err := newMessageID()
+	if err != nil {
+		return "", fmt.Errorf("webhook: %w", err)
+	}
+	payload, _ := json.Marshal(map[string]string{
+		"id":             id,
+		"notificationId": msg.NotificationID,
+		"recipientId":    msg.RecipientID,
+		"subject":        msg.Subject,
+		"body":           msg.Body,
+		"html":           msg.HTML,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Message-ID", id)
+	if len(p.secret) > 0 {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write(payload)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", fmt.Errorf("webhook: %w", err)
+	}
+	return id, nil
+}
+
+// checkStatus turns a non-2xx response into an error quoting the start of its body
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+}
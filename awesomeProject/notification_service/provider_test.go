@@ -0,0 +1,206 @@
+package notification_service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one SMTP session on a local port, without STARTTLS or AUTH, and records the
+// commands and the message data
+type fakeSMTP struct {
+	addr     string
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(textproto.NewConn(conn))
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(c *textproto.Conn) {
+	c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		s.commands = append(s.commands, line)
+		switch verb, _, _ := strings.Cut(strings.ToUpper(line), " "); verb {
+		case "EHLO":
+			c.PrintfLine("250-fake")
+			c.PrintfLine("250 8BITMIME")
+		case "DATA":
+			c.PrintfLine("354 end with .")
+			b, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(b)
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestSMTPProviderSendsMultipartMail(t *testing.T) {
+	srv := newFakeSMTP(t)
+	p := NewSMTPProvider(srv.addr, "noreply@example.com", nil)
+	id, err := p.Send(context.Background(), Message{
+		To:      "ana@example.com",
+		Subject: "Olá",
+		Body:    "plain body",
+		HTML:    "<p>html body</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-srv.done
+
+	var mailFrom, rcptTo bool
+	for _, cmd := range srv.commands {
+		mailFrom = mailFrom || strings.HasPrefix(cmd, "MAIL FROM:<noreply@example.com>")
+		rcptTo = rcptTo || cmd == "RCPT TO:<ana@example.com>"
+	}
+	if !mailFrom || !rcptTo {
+		t.Fatalf("commands %q lack MAIL FROM or RCPT TO", srv.commands)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(srv.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Message-ID"); got != id || !strings.HasSuffix(id, "@127.0.0.1>") {
+		t.Fatalf("Message-ID header %q, Send returned %q", got, id)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "Olá" {
+		t.Fatalf("Subject %q, %v", msg.Header.Get("Subject"), err)
+	}
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("Content-Type %q", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "plain body"},
+		{"text/html; charset=utf-8", "<p>html body</p>"},
+	} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Type") != want.contentType || string(b) != want.body {
+			t.Fatalf("part %q: %q, want %q", part.Header.Get("Content-Type"), b, want.body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Fatalf("more than two parts: %v", err)
+	}
+}
+
+func TestSMTPProviderRejectsHeaderInjection(t *testing.T) {
+	p := NewSMTPProvider("127.0.0.1:1", "noreply@example.com", nil)
+	if _, err := p.Send(context.Background(), Message{To: "a@example.com\r\nBcc: b@example.com"}); err == nil {
+		t.Fatal("address with CRLF accepted")
+	}
+}
+
+func TestSMSGatewayProvider(t *testing.T) {
+	var reply string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if r.Header.Get("Authorization") != "Bearer tok" || json.NewDecoder(r.Body).Decode(&in) != nil ||
+			in["from"] != "+15550000000" || in["to"] != "+14155550100" || in["body"] != "code 1234" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, reply)
+	}))
+	defer srv.Close()
+	p := NewSMSGatewayProvider(srv.URL, "tok", "+15550000000", srv.Client())
+	msg := Message{To: "+14155550100", Body: "code 1234"}
+
+	reply = `{"id": "sms-1"}`
+	if id, err := p.Send(context.Background(), msg); err != nil || id != "sms-1" {
+		t.Fatalf("Send = %q, %v", id, err)
+	}
+	for _, bad := range []string{`{}`, `{"id": ""}`, `not json`} {
+		reply = bad
+		if id, err := p.Send(context.Background(), msg); err == nil {
+			t.Fatalf("reply %s: Send = %q without error", bad, id)
+		}
+	}
+	msg.Body = "other"
+	if _, err := p.Send(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("non-2xx reply: %v", err)
+	}
+}
+
+func TestWebhookProviderSignsPayload(t *testing.T) {
+	const secret = "s3cret"
+	var payload map[string]string
+	var signature, messageID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		signature, messageID = r.Header.Get("X-Signature"), r.Header.Get("X-Message-ID")
+		if !hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil)))) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &payload)
+	}))
+	defer srv.Close()
+
+	p := NewWebhookProvider(secret, srv.Client())
+	id, err := p.Send(context.Background(), Message{NotificationID: "n1", RecipientID: "r1", To: srv.URL, Body: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" || messageID != id || payload["id"] != id {
+		t.Fatalf("id %q, X-Message-ID %q, payload id %q", id, messageID, payload["id"])
+	}
+	if payload["notificationId"] != "n1" || payload["recipientId"] != "r1" || payload["body"] != "hi" {
+		t.Fatalf("payload %v", payload)
+	}
+
+	wrong := NewWebhookProvider("other", srv.Client())
+	if _, err := wrong.Send(context.Background(), Message{To: srv.URL}); err == nil {
+		t.Fatal("wrongly signed webhook accepted")
+	}
+}
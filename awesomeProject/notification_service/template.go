@@ -0,0 +1,174 @@
+package notification_service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// --- Templates ---
+
+// Template is one version of a named notification template for a channel and locale.
+// Subject and Body use text/template and HTML uses html/template, all executed with the
+// notification's Data, e.g. "Hello {{.name}}". Providers without HTML support ignore it.
+type Template struct {
+	Name      string    `json:"name"`
+	Channel   Channel   `json:"channel"`
+	Locale    string    `json:"locale"`            // e.g. "en", "pt-BR"
+	Version   int       `json:"version"`           // assigned by SaveTemplate, starting at 1
+	Subject   string    `json:"subject,omitempty"` // text/template
+	Body      string    `json:"body"`              // text/template
+	HTML      string    `json:"html,omitempty"`    // html/template
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Rendered is a notification rendered for one recipient and channel
+type Rendered struct {
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// compiledTemplate is a stored Template with its parts parsed
+type compiledTemplate struct {
+	Template
+	subject, body *texttemplate.Template
+	html          *htmltemplate.Template // nil without an HTML part
+}
+
+// compileTemplate parses the parts of t. Missing data keys are errors rather than "<no value>".
+func compileTemplate(t Template) (*compiledTemplate, error) {
+	ct := &compiledTemplate{Template: t}
+	var err error
+	if ct.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(t.Subject); err != nil {
+		return nil, err
+	}
+	if ct.body, err = texttemplate.New("body").Option("missingkey=error").Parse(t.Body); err != nil {
+		return nil, err
+	}
+	if t.HTML != "" {
+		if ct.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(t.HTML); err != nil {
+			return nil, err
+		}
+	}
+	return ct, nil
+}
+
+func (ct *compiledTemplate) render(data map[string]interface{}) (Rendered, error) {
+	var out Rendered
+	var buf bytes.Buffer
+	if err := ct.subject.Execute(&buf, data); err != nil {
+		return Rendered{}, err
+	}
+	out.Subject = buf.String()
+	buf.Reset()
+	if err := ct.body.Execute(&buf, data); err != nil {
+		return Rendered{}, err
+	}
+	out.Body = buf.String()
+	if ct.html != nil {
+		buf.Reset()
+		if err := ct.html.Execute(&buf, data); err != nil {
+			return Rendered{}, err
+		}
+		out.HTML = buf.String()
+	}
+	return out, nil
+}
+
+type templateKey struct {
+	name    string
+	channel Channel
+	locale  string
+}
+
+type InMemoryTemplateStore struct {
+	mu            sync.RWMutex
+	versions      map[templateKey][]*compiledTemplate // oldest first
+	defaultLocale string
+}
+
+// NewInMemoryTemplateStore creates a template store falling back to defaultLocale
+func NewInMemoryTemplateStore(defaultLocale string) *InMemoryTemplateStore {
+	return &InMemoryTemplateStore{
+		versions:      make(map[templateKey][]*compiledTemplate),
+		defaultLocale: normalizeLocale(defaultLocale),
+	}
+}
+
+// Save checks that t parses and stores it as the next version of its name, channel and locale
+func (s *InMemoryTemplateStore) Save(t Template) (int, error) {
+	if t.Name == "" || t.Channel == "" {
+		return 0, errors.New("template needs a name and a channel")
+	}
+	t.Locale = normalizeLocale(t.Locale)
+	if t.Locale == "" {
+		t.Locale = s.defaultLocale
+	}
+	ct, err := compileTemplate(t)
+	if err != nil {
+		return 0, fmt.Errorf("template %s: %w", t.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := templateKey{t.Name, t.Channel, t.Locale}
+	ct.Version = len(s.versions[k]) + 1
+	ct.CreatedAt = time.Now().UTC()
+	s.versions[k] = append(s.versions[k], ct)
+	return ct.Version, nil
+}
+
+// Get returns a stored template, the latest version when version is 0. The locale falls back
+// from "pt-BR" to "pt" and then to the default locale.
+func (s *InMemoryTemplateStore) Get(name string, ch Channel, locale string, version int) (Template, error) {
+	ct, err := s.find(name, ch, locale, version)
+	if err != nil {
+		return Template{}, err
+	}
+	return ct.Template, nil
+}
+
+func (s *InMemoryTemplateStore) find(name string, ch Channel, locale string, version int) (*compiledTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, l := range s.fallbacks(normalizeLocale(locale)) {
+		versions := s.versions[templateKey{name, ch, l}]
+		if len(versions) == 0 {
+			continue
+		}
+		if version == 0 {
+			return versions[len(versions)-1], nil
+		}
+		if version > len(versions) {
+			return nil, fmt.Errorf("template %s for %s/%s has no version %d", name, ch, l, version)
+		}
+		return versions[version-1], nil
+	}
+	return nil, fmt.Errorf("no template %s for %s in locale %q or %q", name, ch, locale, s.defaultLocale)
+}
+
+// fallbacks lists the locales to try for locale, most specific first
+func (s *InMemoryTemplateStore) fallbacks(locale string) []string {
+	var ls []string
+	if locale != "" {
+		ls = append(ls, locale)
+		if lang, _, ok := strings.Cut(locale, "-"); ok {
+			ls = append(ls, lang)
+		}
+	}
+	return append(ls, s.defaultLocale)
+}
+
+// normalizeLocale turns "pt_br" into "pt-BR"
+func normalizeLocale(locale string) string {
+	lang, region, ok := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	if !ok {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
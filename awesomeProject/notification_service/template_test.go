@@ -0,0 +1,116 @@
+package notification_service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderInlineText(t *testing.T) {
+	a := NewApp()
+	n := &Notification{Title: "Deals {{today}}", Body: "Hi {{name}}, 50% off"}
+	got, err := a.Preview(n, "r1", ChannelEmail)
+	if err != nil || got.Subject != n.Title || got.Body != n.Body {
+		t.Fatalf("without Data: %+v, %v; want the text as written", got, err)
+	}
+
+	n = &Notification{Title: "Hi {{.name}}", Body: "Your code is {{.code}}", Data: map[string]interface{}{"name": "Ana", "code": 42}}
+	got, err = a.Preview(n, "r1", ChannelEmail)
+	if err != nil || got.Subject != "Hi Ana" || got.Body != "Your code is 42" {
+		t.Fatalf("with Data: %+v, %v", got, err)
+	}
+}
+
+func TestTemplateLocaleFallback(t *testing.T) {
+	a := NewApp()
+	for _, tpl := range []Template{
+		{Name: "welcome", Channel: ChannelEmail, Locale: "en", Subject: "Welcome", Body: "Hello {{.name}}"},
+		{Name: "welcome", Channel: ChannelEmail, Locale: "pt", Subject: "Bem-vindo", Body: "Olá {{.name}}"},
+	} {
+		if _, err := a.SaveTemplate(tpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.UpsertContact(Contact{RecipientID: "from-directory", Email: "d@example.com", Locale: "pt_br"}); err != nil {
+		t.Fatal(err)
+	}
+	n := &Notification{Template: "welcome", Data: map[string]interface{}{"name": "Ana"}, Recipients: []Recipient{
+		{ID: "pt-BR", Locale: "pt-BR"},
+		{ID: "fr", Locale: "fr"},
+		{ID: "none"},
+		{ID: "from-directory"},
+	}}
+	for id, want := range map[string]string{
+		"pt-BR":          "Olá Ana", // no pt-BR template, falls back to pt
+		"fr":             "Hello Ana",
+		"none":           "Hello Ana",
+		"from-directory": "Olá Ana",
+	} {
+		got, err := a.Preview(n, id, ChannelEmail)
+		if err != nil || got.Body != want {
+			t.Errorf("%s: %q, %v; want %q", id, got.Body, err, want)
+		}
+	}
+
+	// a region-specific version takes over from the language
+	if _, err := a.SaveTemplate(Template{Name: "welcome", Channel: ChannelEmail, Locale: "pt_BR", Body: "Oi {{.name}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.Preview(n, "pt-BR", ChannelEmail); err != nil || got.Body != "Oi Ana" {
+		t.Errorf("pt-BR after saving a pt-BR template: %q, %v", got.Body, err)
+	}
+	if _, err := a.Preview(n, "fr", ChannelSMS); err == nil {
+		t.Error("a channel without templates rendered")
+	}
+}
+
+func TestTemplateVersionPinning(t *testing.T) {
+	a := NewApp()
+	for i, body := range []string{"first {{.n}}", "second {{.n}}"} {
+		v, err := a.SaveTemplate(Template{Name: "t", Channel: ChannelSMS, Body: body})
+		if err != nil || v != i+1 {
+			t.Fatalf("SaveTemplate = %d, %v; want version %d", v, err, i+1)
+		}
+	}
+	n := &Notification{Template: "t", Data: map[string]interface{}{"n": 1}}
+	for version, want := range map[int]string{0: "second 1", 1: "first 1", 2: "second 1"} {
+		n.TemplateVersion = version
+		if got, err := a.Preview(n, "r1", ChannelSMS); err != nil || got.Body != want {
+			t.Errorf("version %d: %q, %v; want %q", version, got.Body, err, want)
+		}
+	}
+	n.TemplateVersion = 3
+	if _, err := a.Preview(n, "r1", ChannelSMS); err == nil {
+		t.Error("missing version 3 rendered")
+	}
+	if _, err := a.SaveTemplate(Template{Name: "t", Channel: ChannelSMS, Body: "{{.n"}); err == nil {
+		t.Error("unparseable template saved")
+	}
+}
+
+func TestRenderErrorsFailAttempts(t *testing.T) {
+	email := &recordingProvider{channel: ChannelEmail}
+	a := NewApp(WithProvider(email))
+	if _, err := a.SaveTemplate(Template{Name: "receipt", Channel: ChannelEmail, Body: "Total {{.total}}"}); err != nil {
+		t.Fatal(err)
+	}
+	to := map[string]string{"EMAIL": "ana@example.com"}
+	for i, n := range []*Notification{
+		{Template: "receipt", Data: map[string]interface{}{"currency": "EUR"}}, // missing key
+		{Template: "no-such-template", Data: map[string]interface{}{"total": 1}},
+		{Title: "t", Body: "{{.total", Data: map[string]interface{}{"total": 1}}, // does not parse
+	} {
+		n.ID = fmt.Sprint("n", i)
+		n.Recipients = []Recipient{{ID: "r1", Addresses: to}}
+		if _, _, err := a.CreateNotification(n); err != nil {
+			t.Fatal(err)
+		}
+		att := deliverPending(a)["r1"]
+		if att.Status != AttemptFailed || !strings.HasPrefix(att.Error, "render: ") {
+			t.Errorf("notification %+v: attempt %s %q, want failed with a render error", n, att.Status, att.Error)
+		}
+	}
+	if len(email.sent) != 0 {
+		t.Fatalf("sent %d messages that failed to render", len(email.sent))
+	}
+}
@@ -0,0 +1,281 @@
+package middlewarerouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream is one backend server of a Pool
+type Upstream struct {
+	URL     *url.URL
+	healthy atomic.Bool
+	active  atomic.Int64 // requests in flight
+	passes  int          // consecutive health check results, owned by the checker
+	fails   int
+}
+
+// Healthy reports the result of the latest health checks; upstreams start healthy
+func (u *Upstream) Healthy() bool { return u.healthy.Load() }
+
+// Active returns the number of requests in flight to u
+func (u *Upstream) Active() int64 { return u.active.Load() }
+
+// Balancer picks the upstream for a request among those usable returns true for
+// (healthy and not yet tried by this request)
+type Balancer interface {
+	Pick(r *http.Request, upstreams []*Upstream, usable func(*Upstream) bool) *Upstream
+}
+
+// balancerValidator is implemented by balancers that can be misconfigured; NewPool rejects them
+type balancerValidator interface {
+	validate() error
+}
+
+// ------------------- balancers ------------------
+
+type roundRobin struct{ next atomic.Uint64 }
+
+// RoundRobin cycles through the upstreams
+func RoundRobin() Balancer { return &roundRobin{} }
+
+func (b *roundRobin) Pick(_ *http.Request, ups []*Upstream, usable func(*Upstream) bool) *Upstream {
+	start := b.next.Add(1) - 1
+	for i := range ups {
+		if u := ups[(start+uint64(i))%uint64(len(ups))]; usable(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+type leastConnections struct{ next atomic.Uint64 }
+
+// LeastConnections picks the upstream with the fewest requests in flight, rotating among ties
+func LeastConnections() Balancer { return &leastConnections{} }
+
+func (b *leastConnections) Pick(_ *http.Request, ups []*Upstream, usable func(*Upstream) bool) *Upstream {
+	start := b.next.Add(1) - 1
+	var best *Upstream
+	for i := range ups {
+		u := ups[(start+uint64(i))%uint64(len(ups))]
+		if usable(u) && (best == nil || u.Active() < best.Active()) {
+			best = u
+		}
+	}
+	return best
+}
+
+type consistentHash struct {
+	key func(r *http.Request) string
+}
+
+// ConsistentHash sends requests with the same key (e.g. a user ID header) to the same upstream.
+// It uses rendezvous hashing: each request goes to the usable upstream with the highest
+// hash(key, upstream), so when an upstream fails or is removed only its own keys move.
+// NewPool rejects it if key is nil.
+func ConsistentHash(key func(r *http.Request) string) Balancer {
+	return &consistentHash{key: key}
+}
+
+func (b *consistentHash) validate() error {
+	if b.key == nil {
+		return errors.New("consistent hash balancer needs a key function")
+	}
+	return nil
+}
+
+func (b *consistentHash) Pick(r *http.Request, ups []*Upstream, usable func(*Upstream) bool) *Upstream {
+	key := b.key(r)
+	var best *Upstream
+	var bestScore uint64
+	for _, u := range ups {
+		if !usable(u) {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(u.URL.String()))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = u, score
+		}
+	}
+	return best
+}
+
+// ------------------- pool ------------------
+
+// HealthCheck configures active health checking: every Interval each upstream gets a GET Path,
+// and any 2xx/3xx answer within Timeout is a pass. An upstream is taken out of rotation after
+// UnhealthyAfter consecutive failures and put back after HealthyAfter consecutive passes.
+type HealthCheck struct {
+	Path           string
+	Interval       time.Duration
+	Timeout        time.Duration
+	UnhealthyAfter int // default 2
+	HealthyAfter   int // default 1
+}
+
+// PoolOption configures a Pool
+type PoolOption func(*Pool)
+
+// WithBalancer sets the balancing strategy, RoundRobin by default
+func WithBalancer(b Balancer) PoolOption {
+	return func(p *Pool) {
+		p.balancer = b
+	}
+}
+
+// WithHealthCheck enables active health checks, run until Close
+func WithHealthCheck(hc HealthCheck) PoolOption {
+	return func(p *Pool) {
+		p.health = &hc
+	}
+}
+
+// Pool is a set of interchangeable upstreams
+type Pool struct {
+	upstreams []*Upstream
+	balancer  Balancer
+	health    *HealthCheck
+	client    *http.Client
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+var ErrNoUpstream = errors.New("no healthy upstream")
+
+// NewPool creates a pool of upstream base URLs such as "http://10.0.0.1:8080"
+func NewPool(targets []string, options ...PoolOption) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("pool needs at least one upstream")
+	}
+	p := &Pool{balancer: RoundRobin()}
+	for _, t := range targets {
+		u, err := url.Parse(t)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("bad upstream URL %q", t)
+		}
+		up := &Upstream{URL: u}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	if p.balancer == nil {
+		return nil, errors.New("pool needs a balancer")
+	}
+	if v, ok := p.balancer.(balancerValidator); ok {
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if hc := p.health; hc != nil {
+		if hc.Interval <= 0 {
+			return nil, errors.New("health check interval must be positive")
+		}
+		if hc.Timeout <= 0 || hc.Timeout > hc.Interval {
+			hc.Timeout = hc.Interval
+		}
+		if hc.UnhealthyAfter <= 0 {
+			hc.UnhealthyAfter = 2
+		}
+		if hc.HealthyAfter <= 0 {
+			hc.HealthyAfter = 1
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel, p.client = cancel, &http.Client{Timeout: hc.Timeout}
+		p.wg.Add(1)
+		go p.checkLoop(ctx)
+	}
+	return p, nil
+}
+
+// Upstreams returns the pool members
+func (p *Pool) Upstreams() []*Upstream { return p.upstreams }
+
+// Close stops health checking
+func (p *Pool) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		p.wg.Wait()
+	}
+}
+
+// untried reports whether a healthy upstream the request has not tried yet is left
+func (p *Pool) untried(tried map[*Upstream]bool) bool {
+	for _, u := range p.upstreams {
+		if u.Healthy() && !tried[u] {
+			return true
+		}
+	}
+	return false
+}
+
+// pick chooses a healthy upstream the request has not tried yet
+func (p *Pool) pick(r *http.Request, tried map[*Upstream]bool) *Upstream {
+	return p.balancer.Pick(r, p.upstreams, func(u *Upstream) bool {
+		return u.Healthy() && !tried[u]
+	})
+}
+
+func (p *Pool) checkLoop(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.health.Interval)
+	defer ticker.Stop()
+	for {
+		p.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *Upstream) {
+			defer wg.Done()
+			p.record(u, p.probe(ctx, u))
+		}(u)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) probe(ctx context.Context, u *Upstream) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL.JoinPath(p.health.Path).String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
+
+func (p *Pool) record(u *Upstream, ok bool) {
+	if ok {
+		u.passes, u.fails = u.passes+1, 0
+		if u.passes >= p.health.HealthyAfter {
+			u.healthy.Store(true)
+		}
+		return
+	}
+	u.passes, u.fails = 0, u.fails+1
+	if u.fails >= p.health.UnhealthyAfter {
+		u.healthy.Store(false)
+	}
+}
@@ -0,0 +1,155 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testUpstreams(t *testing.T, n int) []*Upstream {
+	t.Helper()
+	targets := make([]string, n)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("http://10.0.0.%d:8080", i+1)
+	}
+	p, err := NewPool(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Upstreams()
+}
+
+func all(*Upstream) bool { return true }
+
+func TestRoundRobin(t *testing.T) {
+	ups := testUpstreams(t, 3)
+	b := RoundRobin()
+	for i := 0; i < 6; i++ {
+		if got := b.Pick(nil, ups, all); got != ups[i%3] {
+			t.Fatalf("pick %d = %v, want %v", i, got.URL, ups[i%3].URL)
+		}
+	}
+	notFirst := func(u *Upstream) bool { return u != ups[0] }
+	for i := 0; i < 4; i++ {
+		if got := b.Pick(nil, ups, notFirst); got == ups[0] {
+			t.Fatal("picked an unusable upstream")
+		}
+	}
+	if got := b.Pick(nil, ups, func(*Upstream) bool { return false }); got != nil {
+		t.Fatalf("pick without usable upstreams = %v", got.URL)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	ups := testUpstreams(t, 3)
+	ups[0].active.Store(5)
+	ups[1].active.Store(1)
+	ups[2].active.Store(3)
+	b := LeastConnections()
+	for i := 0; i < 3; i++ {
+		if got := b.Pick(nil, ups, all); got != ups[1] {
+			t.Fatalf("pick = %v, want the upstream with 1 in flight", got.URL)
+		}
+	}
+	if got := b.Pick(nil, ups, func(u *Upstream) bool { return u != ups[1] }); got != ups[2] {
+		t.Fatalf("pick without ups[1] = %v, want the upstream with 3 in flight", got.URL)
+	}
+	// ties rotate
+	ups[0].active.Store(0)
+	ups[1].active.Store(0)
+	ups[2].active.Store(0)
+	seen := make(map[*Upstream]bool)
+	for i := 0; i < 3; i++ {
+		seen[b.Pick(nil, ups, all)] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("ties went to %d upstreams, want all 3", len(seen))
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	ups := testUpstreams(t, 4)
+	b := ConsistentHash(func(r *http.Request) string { return r.Header.Get("X-User") })
+	request := func(user string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-User", user)
+		return r
+	}
+
+	owner := make(map[string]*Upstream)
+	counts := make(map[*Upstream]int)
+	for i := 0; i < 200; i++ {
+		user := fmt.Sprint("user", i)
+		owner[user] = b.Pick(request(user), ups, all)
+		counts[owner[user]]++
+		if again := b.Pick(request(user), ups, all); again != owner[user] {
+			t.Fatalf("%s moved from %v to %v", user, owner[user].URL, again.URL)
+		}
+	}
+	if len(counts) != 4 {
+		t.Fatalf("keys spread over %d upstreams, want 4", len(counts))
+	}
+
+	// taking one upstream out only moves its own keys
+	down := ups[2]
+	usable := func(u *Upstream) bool { return u != down }
+	for user, u := range owner {
+		got := b.Pick(request(user), ups, usable)
+		if u != down && got != u {
+			t.Fatalf("%s moved from %v to %v although its upstream is up", user, u.URL, got.URL)
+		}
+		if got == down {
+			t.Fatalf("%s went to the unusable upstream", user)
+		}
+	}
+}
+
+func TestPoolRejectsConsistentHashWithoutKey(t *testing.T) {
+	if _, err := NewPool([]string{"http://10.0.0.1:8080"}, WithBalancer(ConsistentHash(nil))); err == nil {
+		t.Fatal("NewPool accepted ConsistentHash(nil)")
+	}
+}
+
+func TestHealthCheckEjectsAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewPool([]string{srv.URL}, WithHealthCheck(HealthCheck{
+		Path:           "/health",
+		Interval:       5 * time.Millisecond,
+		UnhealthyAfter: 2,
+		HealthyAfter:   2,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	up := p.Upstreams()[0]
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for up.Healthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("upstream healthy = %v, want %v", up.Healthy(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	healthy.Store(false)
+	waitFor(false)
+	if got := p.pick(httptest.NewRequest("GET", "/", nil), map[*Upstream]bool{}); got != nil {
+		t.Fatal("an ejected upstream was picked")
+	}
+	healthy.Store(true)
+	waitFor(true)
+}
@@ -0,0 +1,251 @@
+package middlewarerouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HTTPRouter is an http.Handler dispatching requests by method and path pattern:
+//
+//	/users/:id     ":id" captures one path segment
+//	/files/*path   "*path" captures the rest of the path, including slashes
+//
+// Each method has its own radix tree. When several patterns match, static segments win over
+// parameters and parameters over wildcards, regardless of registration order. Routes may also
+// carry Matchers on host, headers, query or content type; a pattern whose routes all reject the
+// request does not match, and routing falls back to less specific patterns.
+type HTTPRouter struct {
+	trees         map[string]*node
+	middlewares   []Middleware
+	handler       http.Handler // dispatch wrapped in middlewares
+	trailingSlash TrailingSlash
+	notFound      http.Handler
+	notAllowed    http.Handler
+	names         map[string]*namedRoute
+}
+
+// TrailingSlash controls how a path that only matches with/without a trailing slash is handled
+type TrailingSlash int
+
+const (
+	// TrailingSlashRedirect redirects to the registered form (301 for GET, 308 otherwise)
+	TrailingSlashRedirect TrailingSlash = iota
+	// TrailingSlashMatch serves the route as if the path matched exactly
+	TrailingSlashMatch
+	// TrailingSlashStrict treats the two forms as different paths
+	TrailingSlashStrict
+)
+
+// HTTPRouterOption configures an HTTPRouter
+type HTTPRouterOption func(*HTTPRouter)
+
+// WithTrailingSlash sets the trailing slash policy, TrailingSlashRedirect by default
+func WithTrailingSlash(mode TrailingSlash) HTTPRouterOption {
+	return func(r *HTTPRouter) {
+		r.trailingSlash = mode
+	}
+}
+
+// WithNotFound replaces the default 404 handler
+func WithNotFound(h http.Handler) HTTPRouterOption {
+	return func(r *HTTPRouter) {
+		r.notFound = h
+	}
+}
+
+// WithMethodNotAllowed replaces the default 405 handler; the Allow header is already set
+func WithMethodNotAllowed(h http.Handler) HTTPRouterOption {
+	return func(r *HTTPRouter) {
+		r.notAllowed = h
+	}
+}
+
+// NewHTTPRouter creates an empty HTTPRouter
+func NewHTTPRouter(options ...HTTPRouterOption) *HTTPRouter {
+	r := &HTTPRouter{
+		trees:    make(map[string]*node),
+		names:    make(map[string]*namedRoute),
+		notFound: http.NotFoundHandler(),
+		notAllowed: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}),
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	r.handler = http.HandlerFunc(r.dispatch)
+	return r
+}
+
+// AddRoute registers h for method and pattern, only for requests all matchers agree to.
+// A pattern may be registered several times with different matchers; its routes are tried in
+// registration order, and the one without matchers, if any, last. It fails if the pattern is
+// malformed or conflicts with a route already registered for the method.
+func (r *HTTPRouter) AddRoute(method, pattern string, h http.Handler, matchers ...Matcher) error {
+	method = strings.ToUpper(method)
+	root, ok := r.trees[method]
+	if !ok {
+		root = &node{}
+		r.trees[method] = root
+	}
+	n, err := root.insert(pattern)
+	if err != nil {
+		return err
+	}
+	routes, _ := n.value.([]*route)
+	if len(matchers) == 0 && matchRoute(routes, nil) != nil {
+		return fmt.Errorf("pattern %q conflicts with existing route %s", pattern, n.pattern)
+	}
+	n.value = addRoute(routes, &route{handler: h, matchers: matchers})
+	return nil
+}
+
+// HandleFunc registers a handler function, see AddRoute
+func (r *HTTPRouter) HandleFunc(method, pattern string, h func(http.ResponseWriter, *http.Request), matchers ...Matcher) error {
+	return r.AddRoute(method, pattern, http.HandlerFunc(h), matchers...)
+}
+
+// HandleAll registers h for pattern under every standard method, e.g. for a Proxy
+func (r *HTTPRouter) HandleAll(pattern string, h http.Handler, matchers ...Matcher) error {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		if err := r.AddRoute(method, pattern, h, matchers...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup returns the handler and parameters for method and path. A HEAD request falls back
+// to the GET route. Routes with matchers are skipped, as there is no request to match, and the
+// trailing slash policy is not applied.
+func (r *HTTPRouter) Lookup(method, path string) (http.Handler, Params, bool) {
+	_, rt, ps := r.find(method, path, nil)
+	if rt == nil {
+		return nil, nil, false
+	}
+	return rt.handler, ps, true
+}
+
+// find returns the route of method matching path and req (nil to skip routes with matchers)
+func (r *HTTPRouter) find(method, path string, req *http.Request) (*node, *route, Params) {
+	root := r.trees[method]
+	if root == nil && method == http.MethodHead {
+		root = r.trees[http.MethodGet]
+	}
+	if root == nil {
+		return nil, nil, nil
+	}
+	var ps Params
+	var rt *route
+	n := root.lookup(path, &ps, func(n *node) bool {
+		rt = matchRoute(n.value.([]*route), req)
+		return rt != nil
+	})
+	if n == nil {
+		return nil, nil, nil
+	}
+	return n, rt, ps
+}
+
+// Use appends router-level middlewares. They run for every request in the order given, before
+// routing, so they also see redirects, 404 and 405 responses.
+func (r *HTTPRouter) Use(mws ...Middleware) {
+	r.middlewares = append(r.middlewares, mws...)
+	r.handler = chain(r.middlewares, http.HandlerFunc(r.dispatch))
+}
+
+// Group returns a group of routes sharing prefix, e.g. r.Group("/api/v1")
+func (r *HTTPRouter) Group(prefix string) *Group {
+	return &Group{router: r, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+func (r *HTTPRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *HTTPRouter) dispatch(w http.ResponseWriter, req *http.Request) {
+	var hostParams Params
+	req = req.WithContext(context.WithValue(req.Context(), hostParamsKey{}, &hostParams))
+	path := req.URL.Path
+	if n, rt, ps := r.find(req.Method, path, req); n != nil {
+		r.serve(w, req, n, rt, ps)
+		return
+	}
+
+	if r.trailingSlash != TrailingSlashStrict && path != "/" {
+		alt := path + "/"
+		if strings.HasSuffix(path, "/") {
+			alt = path[:len(path)-1]
+		}
+		if n, rt, ps := r.find(req.Method, alt, req); n != nil {
+			if r.trailingSlash == TrailingSlashMatch {
+				r.serve(w, req, n, rt, ps)
+				return
+			}
+			code := http.StatusPermanentRedirect
+			if req.Method == http.MethodGet {
+				code = http.StatusMovedPermanently
+			}
+			u := *req.URL
+			u.Path = alt
+			http.Redirect(w, req, u.String(), code)
+			return
+		}
+	}
+
+	if allow := r.allowed(req); len(allow) > 0 {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		r.notAllowed.ServeHTTP(w, req)
+		return
+	}
+	r.notFound.ServeHTTP(w, req)
+}
+
+func (r *HTTPRouter) serve(w http.ResponseWriter, req *http.Request, n *node, rt *route, ps Params) {
+	if hostParams := req.Context().Value(hostParamsKey{}).(*Params); len(*hostParams) > 0 {
+		ps = append(ps, *hostParams...)
+	}
+	ctx := context.WithValue(req.Context(), routeKey{}, &RouteMatch{Pattern: n.pattern, Params: ps})
+	rt.handler.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// allowed lists the methods with a route for the request path, sorted. Matchers are checked
+// as if the request had been sent with each method.
+func (r *HTTPRouter) allowed(req *http.Request) []string {
+	var allow []string
+	as := req.WithContext(req.Context())
+	for method := range r.trees {
+		as.Method = method
+		if n, _, _ := r.find(method, req.URL.Path, as); n != nil {
+			allow = append(allow, method)
+		}
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+// RouteMatch describes the route that matched a request
+type RouteMatch struct {
+	Pattern string
+	Params  Params // path parameters, followed by those captured by Host matchers
+}
+
+type routeKey struct{}
+
+// MatchFromContext returns the route matched for the request carrying ctx, nil outside HTTPRouter
+func MatchFromContext(ctx context.Context) *RouteMatch {
+	m, _ := ctx.Value(routeKey{}).(*RouteMatch)
+	return m
+}
+
+// PathParam returns the named path parameter of the request's matched route
+func PathParam(req *http.Request, name string) string {
+	if m := MatchFromContext(req.Context()); m != nil {
+		return m.Params.Get(name)
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoRoute answers with the matched pattern and its parameters, e.g. "/users/:id id=7"
+func echoRoute(w http.ResponseWriter, r *http.Request) {
+	m := MatchFromContext(r.Context())
+	out := m.Pattern
+	for _, p := range m.Params {
+		out += " " + p.Key + "=" + p.Value
+	}
+	fmt.Fprint(w, out)
+}
+
+func newEchoRouter(t *testing.T, patterns []string, options ...HTTPRouterOption) *HTTPRouter {
+	t.Helper()
+	r := NewHTTPRouter(options...)
+	for _, p := range patterns {
+		if err := r.HandleFunc("GET", p, echoRoute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+func TestHTTPRouterPrecedenceAndParams(t *testing.T) {
+	// registered least specific first so order cannot explain the results
+	r := newEchoRouter(t, []string{
+		"/*path",
+		"/users/:id",
+		"/users/:id/posts/:post",
+		"/users/new",
+		"/users/new/settings",
+		"/files/*path",
+		"/files/readme",
+		"/",
+	})
+	for _, tc := range []struct{ path, want string }{
+		{"/", "/"},
+		{"/users/new", "/users/new"},
+		{"/users/7", "/users/:id id=7"},
+		{"/users/newer", "/users/:id id=newer"},
+		{"/users/7/posts/9", "/users/:id/posts/:post id=7 post=9"},
+		{"/users/new/settings", "/users/new/settings"},
+		// the static "new" branch has no posts, so lookup backtracks to :id
+		{"/users/new/posts/1", "/users/:id/posts/:post id=new post=1"},
+		{"/files/readme", "/files/readme"},
+		{"/files/a/b/c.txt", "/files/*path path=a/b/c.txt"},
+		{"/files/", "/files/*path path="},
+		{"/users/7/comments", "/*path path=users/7/comments"},
+		{"/other", "/*path path=other"},
+	} {
+		if rec := get(r, tc.path); rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Errorf("GET %s = %d %q, want %q", tc.path, rec.Code, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestHTTPRouterLookupAndHead(t *testing.T) {
+	r := newEchoRouter(t, []string{"/users/:id"})
+	if _, ps, ok := r.Lookup("GET", "/users/42"); !ok || ps.Get("id") != "42" || ps.Get("other") != "" {
+		t.Fatalf("Lookup = %v, %v", ps, ok)
+	}
+	if _, _, ok := r.Lookup("HEAD", "/users/42"); !ok {
+		t.Fatal("HEAD did not fall back to the GET route")
+	}
+	if _, _, ok := r.Lookup("POST", "/users/42"); ok {
+		t.Fatal("Lookup matched a method without routes")
+	}
+	if _, _, ok := r.Lookup("GET", "/users/"); ok {
+		t.Fatal("a parameter matched an empty segment")
+	}
+}
+
+func TestHTTPRouterRejectsConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"parameter names", []string{"/users/:id", "/users/:name/posts"}},
+		{"wildcard names", []string{"/files/*path", "/files/*rest"}},
+		{"same pattern twice", []string{"/users/:id", "/users/:id"}},
+		{"no leading slash", []string{"users"}},
+		{"parameter inside a segment", []string{"/users/a:id"}},
+		{"empty parameter name", []string{"/users/:"}},
+		{"wildcard not last", []string{"/files/*path/meta"}},
+	} {
+		r := NewHTTPRouter()
+		var err error
+		for _, p := range tc.patterns {
+			if err = r.HandleFunc("GET", p, echoRoute); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("%s: %q all registered", tc.name, tc.patterns)
+		}
+	}
+
+	// the same pattern under another method, or with matchers, is fine
+	r := newEchoRouter(t, []string{"/users/:id"})
+	if err := r.HandleFunc("POST", "/users/:id", echoRoute); err != nil {
+		t.Error(err)
+	}
+	if err := r.HandleFunc("GET", "/users/:id", echoRoute, Header("X-Beta", "1")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPRouterTrailingSlash(t *testing.T) {
+	patterns := []string{"/about", "/docs/"}
+	r := newEchoRouter(t, patterns)
+	if err := r.HandleFunc("POST", "/forms/", echoRoute); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		method, target string
+		code           int
+		location       string
+	}{
+		{"GET", "/about/", http.StatusMovedPermanently, "/about"},
+		{"GET", "/docs?page=2", http.StatusMovedPermanently, "/docs/?page=2"},
+		{"POST", "/forms", http.StatusPermanentRedirect, "/forms/"},
+		{"GET", "/about", http.StatusOK, ""},
+		{"GET", "/nothing/", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.code || rec.Header().Get("Location") != tc.location {
+			t.Errorf("%s %s = %d to %q, want %d to %q", tc.method, tc.target, rec.Code, rec.Header().Get("Location"), tc.code, tc.location)
+		}
+	}
+
+	match := newEchoRouter(t, patterns, WithTrailingSlash(TrailingSlashMatch))
+	if rec := get(match, "/about/"); rec.Code != http.StatusOK || rec.Body.String() != "/about" {
+		t.Errorf("TrailingSlashMatch: GET /about/ = %d %q", rec.Code, rec.Body)
+	}
+	strict := newEchoRouter(t, patterns, WithTrailingSlash(TrailingSlashStrict))
+	if rec := get(strict, "/about/"); rec.Code != http.StatusNotFound {
+		t.Errorf("TrailingSlashStrict: GET /about/ = %d", rec.Code)
+	}
+}
+
+func TestHTTPRouterMethodNotAllowed(t *testing.T) {
+	r := newEchoRouter(t, []string{"/items/:id"})
+	for _, method := range []string{"PUT", "delete"} {
+		if err := r.HandleFunc(method, "/items/:id", echoRoute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.HandleFunc("POST", "/items", echoRoute); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("PATCH", "/items/1", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "DELETE, GET, PUT" {
+		t.Fatalf("PATCH /items/1 = %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Allow") != "" {
+		t.Fatalf("GET /missing = %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	custom := NewHTTPRouter(
+		WithMethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})),
+		WithNotFound(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		})),
+	)
+	custom.HandleFunc("GET", "/x", echoRoute)
+	rec = httptest.NewRecorder()
+	custom.ServeHTTP(rec, httptest.NewRequest("POST", "/x", strings.NewReader("")))
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Allow") != "GET" {
+		t.Fatalf("custom 405: %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+	if rec := get(custom, "/y"); rec.Code != http.StatusGone {
+		t.Fatalf("custom 404: %d", rec.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package middlewarerouter
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Matcher is an extra condition a request must meet for a route, on top of its method and
+// path. Matchers are passed to AddRoute and all of them must match:
+//
+//	r.AddRoute("GET", "/users/:id", canary, Header("X-Canary", "true"))
+//	r.AddRoute("GET", "/users/:id", stable)
+//	r.AddRoute("GET", "/dashboard", tenant, Host(":tenant.example.com"))
+type Matcher func(r *http.Request) bool
+
+// route is one handler registered for a method and pattern
+type route struct {
+	handler  http.Handler
+	matchers []Matcher
+}
+
+func (rt *route) match(r *http.Request) bool {
+	for _, m := range rt.matchers {
+		if !m(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// hostParamsKey holds the *Params that Host matchers capture into while HTTPRouter routes a request
+type hostParamsKey struct{}
+
+// matchRoute returns the first route of routes matching r. Routes with matchers come in
+// registration order and the route without matchers, if any, last. A nil r only matches the
+// route without matchers. Host captures are reset before each route is tried, so they are the
+// returned route's.
+func matchRoute(routes []*route, r *http.Request) *route {
+	var captured *Params
+	if r != nil {
+		captured, _ = r.Context().Value(hostParamsKey{}).(*Params)
+	}
+	for _, rt := range routes {
+		if captured != nil {
+			*captured = (*captured)[:0]
+		}
+		if len(rt.matchers) == 0 || (r != nil && rt.match(r)) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// addRoute inserts rt into routes, keeping the route without matchers last
+func addRoute(routes []*route, rt *route) []*route {
+	if n := len(routes); len(rt.matchers) > 0 && n > 0 && len(routes[n-1].matchers) == 0 {
+		return slices.Insert(routes, n-1, rt)
+	}
+	return append(routes, rt)
+}
+
+// ------------------- matchers ------------------
+
+// Host matches the request host, ignoring case and port. A leading "*." matches any subdomain,
+// e.g. "*.example.com" matches "acme.example.com" and "eu.acme.example.com" but not "example.com".
+// A ":name" label matches one label and captures it as a route parameter, read like path
+// parameters with PathParam: ":tenant.example.com" gives "acme" for "acme.example.com".
+func Host(pattern string) Matcher {
+	pattern, wildcard := strings.CutPrefix(strings.TrimSuffix(pattern, "."), "*.")
+	labels := strings.Split(pattern, ".")
+	for i, l := range labels {
+		if !strings.HasPrefix(l, ":") {
+			labels[i] = strings.ToLower(l)
+		}
+	}
+	return func(r *http.Request) bool {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		// compare labels right to left, the rest of host is what "*." matches
+		var ps Params
+		more := true
+		for i := len(labels) - 1; i >= 0; i-- {
+			if !more {
+				return false
+			}
+			label := host
+			if j := strings.LastIndexByte(host, '.'); j >= 0 {
+				label, host = host[j+1:], host[:j]
+			} else {
+				host, more = "", false
+			}
+			if name, ok := strings.CutPrefix(labels[i], ":"); ok && name != "" && label != "" {
+				ps = append(ps, Param{Key: name, Value: label})
+			} else if label != labels[i] {
+				return false
+			}
+		}
+		if wildcard && (!more || host == "") || !wildcard && more {
+			return false
+		}
+		if len(ps) > 0 {
+			if captured, _ := r.Context().Value(hostParamsKey{}).(*Params); captured != nil {
+				slices.Reverse(ps)
+				*captured = append(*captured, ps...)
+			}
+		}
+		return true
+	}
+}
+
+// Methods matches any of the given methods, for routes registered with HandleAll
+func Methods(methods ...string) Matcher {
+	methods = slices.Clone(methods)
+	for i, m := range methods {
+		methods[i] = strings.ToUpper(m)
+	}
+	return func(r *http.Request) bool {
+		return slices.Contains(methods, r.Method)
+	}
+}
+
+// Header matches requests carrying the header with the given value; an empty value only
+// requires the header to be present
+func Header(name, value string) Matcher {
+	return func(r *http.Request) bool {
+		values := r.Header.Values(name)
+		if value == "" {
+			return len(values) > 0
+		}
+		return slices.Contains(values, value)
+	}
+}
+
+// Query matches requests with the query parameter set to value; an empty value only requires
+// the parameter to be present
+func Query(name, value string) Matcher {
+	return func(r *http.Request) bool {
+		values, ok := r.URL.Query()[name]
+		if value == "" {
+			return ok
+		}
+		return slices.Contains(values, value)
+	}
+}
+
+// ContentType matches the media type of the request body, ignoring parameters such as charset.
+// A type may end in "/*", e.g. "image/*".
+func ContentType(types ...string) Matcher {
+	types = slices.Clone(types)
+	for i, t := range types {
+		types[i] = strings.ToLower(t)
+	}
+	return func(r *http.Request) bool {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, t := range types {
+			if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mt, prefix+"/") || t == mt {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Any matches when at least one of ms matches
+func Any(ms ...Matcher) Matcher {
+	return func(r *http.Request) bool {
+		for _, m := range ms {
+			if m(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts m
+func Not(m Matcher) Matcher {
+	return func(r *http.Request) bool {
+		return !m(r)
+	}
+}
@@ -0,0 +1,183 @@
+package middlewarerouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHostMatcher(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com:8080", true},
+		{"example.com.", "example.com.", true},
+		{"Example.COM", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"example.com", "badexample.com", false},
+		{"*.example.com", "acme.example.com", true},
+		{"*.example.com", "eu.acme.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "acmeexample.com", false},
+		{"*.example.com", ".example.com", false},
+		{":tenant.example.com", "acme.example.com", true},
+		{":tenant.example.com", "example.com", false},
+		{":tenant.example.com", "eu.acme.example.com", false},
+		{":tenant.example.com", "acme.example.org", false},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Host = tc.host
+		if got := Host(tc.pattern)(r); got != tc.want {
+			t.Errorf("Host(%q) for %q = %v, want %v", tc.pattern, tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestHostCapturesAreRouteParams(t *testing.T) {
+	r := NewHTTPRouter()
+	r.HandleFunc("GET", "/dashboard/:tab", echoRoute, Host(":tenant.:region.example.com"), Header("X-Beta", ""))
+	r.HandleFunc("GET", "/dashboard/:tab", echoRoute, Host(":tenant.example.com"))
+	r.HandleFunc("GET", "/dashboard/:tab", echoRoute)
+
+	for _, tc := range []struct {
+		host string
+		beta bool
+		want string
+	}{
+		{"acme.eu.example.com", true, "/dashboard/:tab tab=usage tenant=acme region=eu"},
+		// the host matches the first route but the header does not; its captures must not leak
+		{"acme.eu.example.com", false, "/dashboard/:tab tab=usage"},
+		{"Acme.example.com", false, "/dashboard/:tab tab=usage tenant=acme"},
+		{"example.com", true, "/dashboard/:tab tab=usage"},
+	} {
+		req := httptest.NewRequest("GET", "/dashboard/usage", nil)
+		req.Host = tc.host
+		if tc.beta {
+			req.Header.Set("X-Beta", "1")
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Body.String() != tc.want {
+			t.Errorf("host %s, beta %v: %q, want %q", tc.host, tc.beta, rec.Body, tc.want)
+		}
+	}
+
+	r.HandleFunc("GET", "/tenant", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(PathParam(req, "tenant")))
+	}, Host(":tenant.example.com"))
+	req := httptest.NewRequest("GET", "/tenant", nil)
+	req.Host = "globex.example.com:443"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Body.String() != "globex" {
+		t.Fatalf("PathParam(tenant) = %q", rec.Body)
+	}
+}
+
+func TestRequestMatchers(t *testing.T) {
+	json := httptest.NewRequest("POST", "/?v=2&debug", strings.NewReader("{}"))
+	json.Header.Set("Content-Type", "Application/JSON; charset=utf-8")
+	json.Header.Add("X-Canary", "false")
+	json.Header.Add("X-Canary", "true")
+	png := httptest.NewRequest("PUT", "/?v=1", nil)
+	png.Header.Set("Content-Type", "image/png")
+
+	for name, tc := range map[string]struct {
+		m         Matcher
+		json, png bool
+	}{
+		"Methods":                {Methods("post", "PATCH"), true, false},
+		"Header value":           {Header("X-Canary", "true"), true, false},
+		"Header present":         {Header("x-canary", ""), true, false},
+		"Header other value":     {Header("X-Canary", "maybe"), false, false},
+		"Query value":            {Query("v", "1"), false, true},
+		"Query present":          {Query("debug", ""), true, false},
+		"ContentType":            {ContentType("application/json"), true, false},
+		"ContentType wildcard":   {ContentType("text/plain", "image/*"), false, true},
+		"ContentType missing":    {ContentType("*/*"), false, false},
+		"Any":                    {Any(Query("v", "1"), Header("X-Canary", "")), true, true},
+		"Any of nothing":         {Any(), false, false},
+		"Not":                    {Not(Methods("PUT")), true, false},
+		"Not Any":                {Not(Any(Methods("GET"), Query("v", "2"))), false, true},
+		"ContentType Not Header": {Any(ContentType("image/*"), Not(Header("X-Canary", ""))), false, true},
+	} {
+		if got := tc.m(json); got != tc.json {
+			t.Errorf("%s on the JSON POST = %v", name, got)
+		}
+		if got := tc.m(png); got != tc.png {
+			t.Errorf("%s on the PNG PUT = %v", name, got)
+		}
+	}
+}
+
+func TestMatchersCombineAndFallThrough(t *testing.T) {
+	r := NewHTTPRouter()
+	r.HandleFunc("GET", "/users/:id", echoRoute) // without matchers: tried last whatever the order
+	r.HandleFunc("GET", "/users/:id", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("canary"))
+	}, Header("X-Canary", "true"), Query("beta", ""))
+	r.HandleFunc("GET", "/users/me", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("me"))
+	}, Header("Authorization", ""))
+	r.HandleFunc("GET", "/*path", echoRoute)
+	r.HandleFunc("GET", "/admin/*path", echoRoute, Host("admin.example.com"))
+
+	send := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, tc := range []struct {
+		method, target string
+		header         []string
+		want           string
+	}{
+		{"GET", "/users/7?beta", []string{"X-Canary", "true"}, "canary"},
+		// every matcher of a route must agree
+		{"GET", "/users/7", []string{"X-Canary", "true"}, "/users/:id id=7"},
+		{"GET", "/users/7?beta", nil, "/users/:id id=7"},
+		{"GET", "/users/me", []string{"Authorization", "Bearer x"}, "me"},
+		// the literal route rejects the request, so routing falls back to the parameter
+		{"GET", "/users/me", nil, "/users/:id id=me"},
+		{"GET", "/users/me?beta", []string{"X-Canary", "true"}, "canary"},
+		// and further back to the wildcard of a less specific pattern
+		{"GET", "/admin/settings", nil, "/*path path=admin/settings"},
+	} {
+		if rec := send(tc.method, tc.target, tc.header...); rec.Body.String() != tc.want {
+			t.Errorf("%s %s %v = %d %q, want %q", tc.method, tc.target, tc.header, rec.Code, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestMethodsMatcherWithHandleAll(t *testing.T) {
+	r := NewHTTPRouter()
+	if err := r.HandleAll("/upload", http.HandlerFunc(echoRoute), Methods("POST", "PUT"), ContentType("image/*")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		method, contentType string
+		code                int
+		allow               string
+	}{
+		{"PUT", "image/png", http.StatusOK, ""},
+		// rejected under this method: 405 lists the methods whose matchers would accept it
+		{"GET", "image/png", http.StatusMethodNotAllowed, "POST, PUT"},
+		// rejected under every method
+		{"POST", "text/plain", http.StatusNotFound, ""},
+	} {
+		req := httptest.NewRequest(tc.method, "/upload", nil)
+		req.Header.Set("Content-Type", tc.contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tc.code || rec.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s /upload as %s = %d, Allow %q; want %d, Allow %q", tc.method, tc.contentType, rec.Code, rec.Header().Get("Allow"), tc.code, tc.allow)
+		}
+	}
+}
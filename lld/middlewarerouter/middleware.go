@@ -0,0 +1,166 @@
+package middlewarerouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Middleware wraps a handler. It may act before and after calling next, or short-circuit by
+// writing a response without calling next at all.
+type Middleware func(next http.Handler) http.Handler
+
+// chain wraps h so mws run in order: mws[0] first
+func chain(mws []Middleware, h http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Group registers routes under a shared prefix with shared middlewares. Group middlewares run
+// after router-level ones, outermost group first, and only for routes registered after Use.
+type Group struct {
+	router      *HTTPRouter
+	prefix      string
+	middlewares []Middleware
+}
+
+// Use appends middlewares for routes registered on the group from now on
+func (g *Group) Use(mws ...Middleware) {
+	g.middlewares = append(g.middlewares, mws...)
+}
+
+// Group returns a nested group; it inherits the prefix and current middlewares of g
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		router:      g.router,
+		prefix:      g.prefix + strings.TrimSuffix(prefix, "/"),
+		middlewares: append([]Middleware(nil), g.middlewares...),
+	}
+}
+
+// AddRoute registers h for method and the group prefix followed by pattern
+func (g *Group) AddRoute(method, pattern string, h http.Handler, matchers ...Matcher) error {
+	return g.router.AddRoute(method, g.prefix+pattern, chain(g.middlewares, h), matchers...)
+}
+
+// Name names the group prefix followed by pattern, see HTTPRouter.Name
+func (g *Group) Name(name, pattern string) error {
+	return g.router.Name(name, g.prefix+pattern)
+}
+
+// HandleFunc registers a handler function, see AddRoute
+func (g *Group) HandleFunc(method, pattern string, h func(http.ResponseWriter, *http.Request), matchers ...Matcher) error {
+	return g.AddRoute(method, pattern, http.HandlerFunc(h), matchers...)
+}
+
+// ------------------- built-in middlewares ------------------
+
+type requestIDKey struct{}
+
+// RequestID makes sure every request has an ID: it keeps a valid incoming X-Request-ID or
+// generates one, echoes it in the response and stores it for RequestIDFromContext
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-ID")
+			if id == "" || len(id) > 128 {
+				b := make([]byte, 12)
+				rand.Read(b)
+				id = hex.EncodeToString(b)
+			}
+			w.Header().Set("X-Request-ID", id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+// RequestIDFromContext returns the ID set by RequestID, "" without it
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Recover turns a panicking handler into a 500 response and logs the panic with its stack.
+// If the handler had already started its response, a 500 can no longer be sent, so the
+// response is aborted with http.ErrAbortHandler instead. http.ErrAbortHandler is re-panicked,
+// as net/http uses it to abort a response on purpose.
+func Recover(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logger.Printf("panic serving %s %s (request %s): %v\n%s",
+					r.Method, r.URL.Path, RequestIDFromContext(r.Context()), v, debug.Stack())
+				if sw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// Logger logs one line per request with status, size and duration
+func Logger(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			logger.Printf("%s %s %d %dB %s request=%s",
+				r.Method, r.URL.RequestURI(), sw.status, sw.size, time.Since(start), RequestIDFromContext(r.Context()))
+		})
+	}
+}
+
+// Timeout cancels the request context after d and answers 503 if the handler has not
+// responded by then, see http.TimeoutHandler
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, http.StatusText(http.StatusServiceUnavailable))
+	}
+}
+
+// statusWriter records the status code and body size written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
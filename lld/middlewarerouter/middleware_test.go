@@ -0,0 +1,146 @@
+package middlewarerouter
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tag is a middleware appending name to the X-Trace response header before calling next
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func trace(rec *httptest.ResponseRecorder) string {
+	return strings.Join(rec.Header().Values("X-Trace"), ",")
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	r := NewHTTPRouter()
+	r.Use(tag("r1"), tag("r2"))
+	api := r.Group("/api")
+	api.Use(tag("g1"))
+	api.HandleFunc("GET", "/before", echoRoute)
+	api.Use(tag("g2"))
+	v1 := api.Group("/v1/")
+	v1.Use(tag("v1"))
+	api.Use(tag("g3")) // after the nested group was created: not inherited
+	v1.HandleFunc("GET", "/users/:id", echoRoute)
+	api.HandleFunc("GET", "/after", echoRoute)
+	r.Use(tag("r3")) // router-level middlewares apply to routes registered before them too
+
+	for _, tc := range []struct{ path, body, trace string }{
+		{"/api/before", "/api/before", "r1,r2,r3,g1"},
+		{"/api/v1/users/7", "/api/v1/users/:id id=7", "r1,r2,r3,g1,g2,v1"},
+		{"/api/after", "/api/after", "r1,r2,r3,g1,g2,g3"},
+		{"/api/missing", "404 page not found\n", "r1,r2,r3"},
+	} {
+		rec := get(r, tc.path)
+		if rec.Body.String() != tc.body || trace(rec) != tc.trace {
+			t.Errorf("GET %s = %q through %s, want %q through %s", tc.path, rec.Body, trace(rec), tc.body, tc.trace)
+		}
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	r := NewHTTPRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.HandleFunc("GET", "/", echoRoute)
+	if rec := get(r, "/"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without Authorization: %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer x")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("with Authorization: %d", rec.Code)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var logged bytes.Buffer
+	r := NewHTTPRouter()
+	r.Use(RequestID(), Recover(log.New(&logged, "", 0)))
+	r.HandleFunc("GET", "/early", func(http.ResponseWriter, *http.Request) { panic("boom") })
+	r.HandleFunc("GET", "/late", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "partial")
+		panic("boom")
+	})
+	r.HandleFunc("GET", "/abort", func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) })
+
+	req := httptest.NewRequest("GET", "/early", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "Internal Server Error\n" {
+		t.Fatalf("panic before writing: %d %q", rec.Code, rec.Body)
+	}
+	if !strings.Contains(logged.String(), "panic serving GET /early (request req-1): boom") {
+		t.Fatalf("log: %s", logged.String())
+	}
+
+	for _, path := range []string{"/late", "/abort"} {
+		logged.Reset()
+		rec := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if v := recover(); v != http.ErrAbortHandler {
+					t.Errorf("GET %s: panicked with %v, want http.ErrAbortHandler", path, v)
+				}
+			}()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		}()
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Internal Server Error") {
+			t.Errorf("GET %s: a 500 was written into the started response: %d %q", path, rec.Code, rec.Body)
+		}
+		if logged := logged.Len() > 0; logged != (path == "/late") {
+			t.Errorf("GET %s: logged = %v", path, logged)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	canceled := make(chan struct{})
+	r := NewHTTPRouter()
+	r.Use(Timeout(20 * time.Millisecond))
+	r.HandleFunc("GET", "/slow", func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		close(canceled)
+	})
+	r.HandleFunc("GET", "/fast", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "done")
+	})
+
+	rec := get(r, "/slow")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "Service Unavailable" {
+		t.Fatalf("slow handler: %d %q", rec.Code, rec.Body)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow handler's context was not canceled")
+	}
+	if rec := get(r, "/fast"); rec.Code != http.StatusCreated || rec.Body.String() != "done" {
+		t.Fatalf("fast handler: %d %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package middlewarerouter
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openAPIMethods are the methods OpenAPI 3 can describe; routes of other methods are left out
+var openAPIMethods = map[string]bool{
+	"GET": true, "PUT": true, "POST": true, "DELETE": true,
+	"OPTIONS": true, "HEAD": true, "PATCH": true, "TRACE": true,
+}
+
+type openAPIDoc struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    openAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string            `json:"name"`
+	In          string            `json:"in"`
+	Required    bool              `json:"required"`
+	Description string            `json:"description,omitempty"`
+	Schema      map[string]string `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// OpenAPI exports the registered routes as an OpenAPI 3 skeleton in JSON: one path per pattern,
+// with ":id" and "*path" written as "{id}" and "{path}", one operation per method and the path
+// parameters. Operations of named patterns get the operationId "<method>_<name>", e.g.
+// "get_user". Responses are left as a "default" placeholder to fill in.
+func (r *HTTPRouter) OpenAPI(title, version string) ([]byte, error) {
+	named := make(map[string]string, len(r.names)) // pattern -> name
+	for name, nr := range r.names {
+		named[nr.pattern] = name
+	}
+	doc := openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: title, Version: version},
+		Paths:   make(map[string]map[string]openAPIOperation),
+	}
+	for method, root := range r.trees {
+		if !openAPIMethods[method] {
+			continue
+		}
+		root.walk(func(n *node) {
+			tokens, _ := parsePattern(n.pattern) // registered patterns are valid
+			var path strings.Builder
+			var params []openAPIParameter
+			for _, t := range tokens {
+				if t.kind == 0 {
+					path.WriteString(t.text)
+					continue
+				}
+				path.WriteString("{" + t.text + "}")
+				p := openAPIParameter{Name: t.text, In: "path", Required: true, Schema: map[string]string{"type": "string"}}
+				if t.kind == '*' {
+					p.Description = "rest of the path, may contain slashes"
+				}
+				params = append(params, p)
+			}
+			op := openAPIOperation{
+				Parameters: params,
+				Responses:  map[string]openAPIResponse{"default": {Description: "response"}},
+			}
+			if name, ok := named[n.pattern]; ok {
+				op.OperationID = strings.ToLower(method) + "_" + name
+			}
+			ops := doc.Paths[path.String()]
+			if ops == nil {
+				ops = make(map[string]openAPIOperation)
+				doc.Paths[path.String()] = ops
+			}
+			ops[strings.ToLower(method)] = op
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
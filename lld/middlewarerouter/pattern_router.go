@@ -0,0 +1,332 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PatternRouter maps path patterns to result strings like SimpleRouter, but patterns may contain
+// segment wildcards and captures:
+//
+//	/users/:id/profile   ":id" matches one segment and captures it as "id"
+//	/static/*/logo.png   "*" matches one segment, captured under the key "*"
+//
+// When several patterns match, the most specific wins, comparing segments left to right:
+// a literal segment beats ":param", which beats "*". Lookups walk a segment trie, so their cost
+// depends on the path length rather than the number of routes. Unlike Router, CallRoute also
+// returns the captured values.
+//
+// The table is safe for concurrent use: writes swap in a new version atomically, and reads
+// never block.
+type PatternRouter struct {
+	mu   sync.Mutex // serializes writers; readers only load root
+	root atomic.Pointer[segmentNode]
+}
+
+// segmentNode is immutable once published: writers copy the nodes along the path they change
+// and swap in the new root, so CallRoute never takes a lock
+type segmentNode struct {
+	static map[string]*segmentNode
+	param  *segmentNode // ":name" segments; names are kept per route in route.names
+	star   *segmentNode // "*" segments
+	route  *patternRoute
+}
+
+type patternRoute struct {
+	pattern string
+	result  string
+	names   []string // capture key of each non-literal segment, in order
+}
+
+// NewPatternRouter creates an empty PatternRouter
+func NewPatternRouter() *PatternRouter {
+	r := &PatternRouter{}
+	r.root.Store(&segmentNode{})
+	return r
+}
+
+// AddRoute adds a route pattern and its result. Re-adding a pattern of the same shape
+// (e.g. "/users/:id" after "/users/:uid") replaces the previous route. Malformed patterns,
+// like "/a*" or "/:", are rejected.
+func (r *PatternRouter) AddRoute(path string, result string) error {
+	path = normalizePath(path)
+	if err := validatePattern(path); err != nil {
+		return err
+	}
+	segs, names := splitPattern(path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.root.Store(r.root.Load().put(segs, &patternRoute{pattern: path, result: result, names: names}))
+	return nil
+}
+
+// RemoveRoute removes the route with the shape of path and reports whether there was one
+func (r *PatternRouter) RemoveRoute(path string) bool {
+	segs, _ := splitPattern(normalizePath(path))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root, ok := r.root.Load().del(segs)
+	if ok {
+		if root == nil {
+			root = &segmentNode{}
+		}
+		r.root.Store(root)
+	}
+	return ok
+}
+
+// Load validates routes and replaces the whole table with them in one atomic swap. Malformed
+// patterns, or two patterns of the same shape, are rejected and leave the table untouched.
+// The new trie is private until published, so it is built in place rather than copied per route.
+func (r *PatternRouter) Load(routes []RouteEntry) error {
+	root := &segmentNode{}
+	seen := make(map[string]string) // shape -> pattern
+	for _, e := range routes {
+		path := normalizePath(e.Path)
+		if err := validatePattern(path); err != nil {
+			return err
+		}
+		segs, names := splitPattern(path)
+		shape := strings.Join(segs, "/")
+		if prev, ok := seen[shape]; ok {
+			return fmt.Errorf("route %q conflicts with %q", path, prev)
+		}
+		seen[shape] = path
+		root.insert(segs, &patternRoute{pattern: path, result: e.Result, names: names})
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.root.Store(root)
+	return nil
+}
+
+// Routes returns the registered routes, sorted by pattern
+func (r *PatternRouter) Routes() []RouteEntry {
+	var routes []RouteEntry
+	r.root.Load().walk(func(route *patternRoute) {
+		routes = append(routes, RouteEntry{Path: route.pattern, Result: route.result})
+	})
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes
+}
+
+// splitPattern returns the segments of a pattern with captures reduced to their kind
+// (":" or "*"), which is the route's shape, and the capture names
+func splitPattern(path string) (segs, names []string) {
+	forEachSegment(path, func(seg string) {
+		switch {
+		case seg == "*":
+			segs, names = append(segs, "*"), append(names, "*")
+		case len(seg) > 1 && seg[0] == ':':
+			segs, names = append(segs, ":"), append(names, seg[1:])
+		default:
+			segs = append(segs, seg)
+		}
+	})
+	return segs, names
+}
+
+// validatePattern rejects patterns AddRoute would silently take literally, like "/a*" or "/:"
+func validatePattern(path string) error {
+	var err error
+	forEachSegment(path, func(seg string) {
+		switch {
+		case err != nil || seg == "*":
+		case seg == ":":
+			err = fmt.Errorf("route %q: capture without a name", path)
+		case strings.ContainsAny(seg, "*") || strings.Contains(seg[1:], ":"):
+			err = fmt.Errorf("route %q: '*' and ':' must make up a whole segment", path)
+		}
+	})
+	return err
+}
+
+// insert stores route at segs, modifying n in place. Only for tries not yet published.
+func (n *segmentNode) insert(segs []string, route *patternRoute) {
+	for _, seg := range segs {
+		var child **segmentNode
+		switch seg {
+		case "*":
+			child = &n.star
+		case ":":
+			child = &n.param
+		default:
+			if n.static == nil {
+				n.static = make(map[string]*segmentNode)
+			}
+			next, ok := n.static[seg]
+			if !ok {
+				next = &segmentNode{}
+				n.static[seg] = next
+			}
+			n = next
+			continue
+		}
+		if *child == nil {
+			*child = &segmentNode{}
+		}
+		n = *child
+	}
+	n.route = route
+}
+
+// put returns a copy of n with route stored at segs; nodes off the path are shared
+func (n *segmentNode) put(segs []string, route *patternRoute) *segmentNode {
+	c := &segmentNode{}
+	if n != nil {
+		*c = *n
+	}
+	if len(segs) == 0 {
+		c.route = route
+		return c
+	}
+	switch seg := segs[0]; seg {
+	case "*":
+		c.star = c.star.put(segs[1:], route)
+	case ":":
+		c.param = c.param.put(segs[1:], route)
+	default:
+		c.static = maps.Clone(c.static)
+		if c.static == nil {
+			c.static = make(map[string]*segmentNode)
+		}
+		c.static[seg] = c.static[seg].put(segs[1:], route)
+	}
+	return c
+}
+
+// del returns a copy of n without the route at segs, pruning emptied nodes (nil if n empties)
+func (n *segmentNode) del(segs []string) (*segmentNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	c := *n
+	if len(segs) == 0 {
+		if n.route == nil {
+			return n, false
+		}
+		c.route = nil
+	} else {
+		var child **segmentNode
+		switch seg := segs[0]; seg {
+		case "*":
+			child = &c.star
+		case ":":
+			child = &c.param
+		default:
+			next, ok := n.static[seg].del(segs[1:])
+			if !ok {
+				return n, false
+			}
+			c.static = maps.Clone(n.static)
+			if next == nil {
+				delete(c.static, seg)
+			} else {
+				c.static[seg] = next
+			}
+			return c.orNil(), true
+		}
+		next, ok := (*child).del(segs[1:])
+		if !ok {
+			return n, false
+		}
+		*child = next
+	}
+	return c.orNil(), true
+}
+
+func (n *segmentNode) orNil() *segmentNode {
+	if n.route == nil && len(n.static) == 0 && n.param == nil && n.star == nil {
+		return nil
+	}
+	return n
+}
+
+func (n *segmentNode) walk(fn func(route *patternRoute)) {
+	if n == nil {
+		return
+	}
+	if n.route != nil {
+		fn(n.route)
+	}
+	for _, c := range n.static {
+		c.walk(fn)
+	}
+	n.param.walk(fn)
+	n.star.walk(fn)
+}
+
+// CallRoute returns the result of the most specific route matching path and the values it captured
+func (r *PatternRouter) CallRoute(path string) (string, Params, error) {
+	path = normalizePath(path)
+	var values []string
+	route := r.root.Load().match(path[1:], &values)
+	if route == nil {
+		return "", nil, fmt.Errorf("route not found: %s", path)
+	}
+	var ps Params
+	if len(values) > 0 {
+		ps = make(Params, len(values))
+		for i, v := range values {
+			ps[i] = Param{Key: route.names[i], Value: v}
+		}
+	}
+	return route.result, ps, nil
+}
+
+// match matches the remaining segments in rest (no leading slash), backtracking from literal
+// to ":param" to "*" children when a more specific branch dead-ends
+func (n *segmentNode) match(rest string, values *[]string) *patternRoute {
+	seg, tail, last := strings.Cut(rest, "/")
+	last = !last
+	step := func(child *segmentNode) *patternRoute {
+		if last {
+			return child.route
+		}
+		return child.match(tail, values)
+	}
+	if child, ok := n.static[seg]; ok {
+		if route := step(child); route != nil {
+			return route
+		}
+	}
+	if seg == "" {
+		return nil // captures never match an empty segment
+	}
+	for _, child := range []*segmentNode{n.param, n.star} {
+		if child == nil {
+			continue
+		}
+		*values = append(*values, seg)
+		if route := step(child); route != nil {
+			return route
+		}
+		*values = (*values)[:len(*values)-1]
+	}
+	return nil
+}
+
+// normalizePath ensures the path starts with a "/"
+func normalizePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// forEachSegment calls fn with each "/"-separated segment of path, which starts with "/"
+func forEachSegment(path string, fn func(seg string)) {
+	rest := path[1:]
+	for {
+		seg, tail, more := strings.Cut(rest, "/")
+		fn(seg)
+		if !more {
+			return
+		}
+		rest = tail
+	}
+}
@@ -0,0 +1,220 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// manyRoutes returns n routes spread over n/10 services:
+// "/svcN/items", "/svcN/items/:id", "/svcN/items/:id/tags/*" and seven more shapes
+func manyRoutes(n int) []RouteEntry {
+	shapes := []string{"items", "items/:id", "items/:id/tags/*", "users/:user/items/:id", "files/*/meta",
+		"orders", "orders/:id", "orders/:id/lines/:line", "search", "health"}
+	routes := make([]RouteEntry, 0, n)
+	for i := 0; len(routes) < n; i++ {
+		for _, shape := range shapes[:min(len(shapes), n-len(routes))] {
+			path := fmt.Sprintf("/svc%d/%s", i, shape)
+			routes = append(routes, RouteEntry{Path: path, Result: path})
+		}
+	}
+	return routes
+}
+
+func TestPatternRouterLoadScales(t *testing.T) {
+	const n = 50000
+	r := NewPatternRouter()
+	start := time.Now()
+	if err := r.Load(manyRoutes(n)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("loading %d routes took %v", n, elapsed)
+	}
+	if got := len(r.Routes()); got != n {
+		t.Fatalf("%d routes loaded, want %d", got, n)
+	}
+	if res, ps, err := r.CallRoute("/svc4999/orders/7/lines/2"); err != nil || res != "/svc4999/orders/:id/lines/:line" || ps.Get("line") != "2" {
+		t.Fatalf("CallRoute = %q, %v, %v", res, ps, err)
+	}
+}
+
+func TestPatternRouterLoadRejectsAndKeepsTable(t *testing.T) {
+	r := NewPatternRouter()
+	if err := r.Load([]RouteEntry{{Path: "/users/:id", Result: "users"}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, routes := range map[string][]RouteEntry{
+		"same pattern": {{Path: "/a", Result: "1"}, {Path: "a", Result: "2"}},
+		"same shape":   {{Path: "/orders/:id", Result: "1"}, {Path: "/orders/:oid", Result: "2"}},
+		"malformed":    {{Path: "/a", Result: "1"}, {Path: "/files/*.png", Result: "2"}},
+	} {
+		if err := r.Load(routes); err == nil {
+			t.Errorf("%s: Load(%v) succeeded", name, routes)
+		}
+		if res, ps, err := r.CallRoute("/users/7"); err != nil || res != "users" || ps.Get("id") != "7" {
+			t.Fatalf("%s: the failed Load changed the table: %q, %v, %v", name, res, ps, err)
+		}
+		if _, _, err := r.CallRoute("/a"); err == nil {
+			t.Fatalf("%s: a route of the failed Load is served", name)
+		}
+	}
+}
+
+func TestPatternRouterAddRouteRejectsMalformed(t *testing.T) {
+	r := NewPatternRouter()
+	for _, path := range []string{"/a*", "/:", "/files/:id:ext", "/x/*y/z", "/a:b"} {
+		if err := r.AddRoute(path, "bad"); err == nil {
+			t.Errorf("AddRoute(%q) accepted", path)
+		}
+	}
+	if routes := r.Routes(); len(routes) != 0 {
+		t.Fatalf("malformed routes were added: %v", routes)
+	}
+	for _, path := range []string{"/a/*", "/:id", "/files/:name/raw", "a/b"} {
+		if err := r.AddRoute(path, "ok"); err != nil {
+			t.Errorf("AddRoute(%q) = %v", path, err)
+		}
+	}
+}
+
+func TestPatternRouterRemoveRoute(t *testing.T) {
+	r := NewPatternRouter()
+	for _, path := range []string{"/users/:id", "/users/:id/posts", "/users/me", "/static/*"} {
+		if err := r.AddRoute(path, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := r.root.Load()
+
+	// removal goes by shape, so ":uid" names the same route as ":id"
+	if !r.RemoveRoute("/users/:uid") || r.RemoveRoute("/users/:id") {
+		t.Fatal("RemoveRoute should succeed once")
+	}
+	if r.RemoveRoute("/users/:id/comments") || r.RemoveRoute("/nothing") {
+		t.Fatal("removed a route that was never added")
+	}
+	for path, want := range map[string]string{
+		"/users/me":       "/users/me",
+		"/users/7/posts":  "/users/:id/posts",
+		"/static/app.css": "/static/*",
+		"/users/7":        "",
+	} {
+		if res, _, err := r.CallRoute(path); res != want || (want == "") != (err != nil) {
+			t.Errorf("CallRoute(%s) = %q, %v; want %q", path, res, err, want)
+		}
+	}
+	// the published version a reader may still hold is not modified
+	if res := before.match("users/7", new([]string)); res == nil || res.pattern != "/users/:id" {
+		t.Fatal("RemoveRoute modified the previous version of the table")
+	}
+
+	for _, path := range []string{"/users/me", "/users/:id/posts", "/static/*"} {
+		r.RemoveRoute(path)
+	}
+	if root := r.root.Load(); root == nil || len(r.Routes()) != 0 {
+		t.Fatalf("table not empty after removing every route: %v", r.Routes())
+	}
+	if _, _, err := r.CallRoute("/users/me"); err == nil {
+		t.Fatal("empty table matched")
+	}
+}
+
+func TestPatternRouterSpecificity(t *testing.T) {
+	r := NewPatternRouter()
+	// registered least specific first so order cannot explain the results
+	for _, path := range []string{
+		"/*/*/*",
+		"/*/logo.png",
+		"/static/*/logo.png",
+		"/:a/:b/:c",
+		"/users/:id",
+		"/users/:id/profile",
+		"/users/me",
+		"/users/me/settings",
+		"/:section/index",
+	} {
+		if err := r.AddRoute(path, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct{ path, want, params string }{
+		{"/users/me", "/users/me", "[]"},
+		{"/users/7", "/users/:id", "[{id 7}]"},
+		{"/users/me/settings", "/users/me/settings", "[]"},
+		// the literal "me" branch has no profile, so matching backtracks to :id
+		{"/users/me/profile", "/users/:id/profile", "[{id me}]"},
+		// a literal beats a capture in the first segment that differs
+		{"/static/img/logo.png", "/static/*/logo.png", "[{* img}]"},
+		{"/blog/index", "/:section/index", "[{section blog}]"},
+		{"/cdn/logo.png", "/*/logo.png", "[{* cdn}]"},
+		// ":param" beats "*"
+		{"/a/b/c", "/:a/:b/:c", "[{a a} {b b} {c c}]"},
+		{"/users/7/posts", "/:a/:b/:c", "[{a users} {b 7} {c posts}]"},
+	} {
+		res, ps, err := r.CallRoute(tc.path)
+		if err != nil || res != tc.want || fmt.Sprint(ps) != tc.params {
+			t.Errorf("CallRoute(%s) = %q %v, %v; want %q %s", tc.path, res, ps, err, tc.want, tc.params)
+		}
+	}
+	for _, path := range []string{"/users", "/users/7/profile/x", "/users//profile", "/a/b/c/d"} {
+		if res, _, err := r.CallRoute(path); err == nil {
+			t.Errorf("CallRoute(%s) matched %q", path, res)
+		}
+	}
+}
+
+func TestPatternRouterParams(t *testing.T) {
+	r := NewPatternRouter()
+	r.AddRoute("/orgs/:org/repos/:repo/files/*", "file")
+	_, ps, err := r.CallRoute("orgs/acme/repos/api/files/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.Get("org") != "acme" || ps.Get("repo") != "api" || ps.Get("*") != "main.go" || len(ps) != 3 {
+		t.Fatalf("params %v", ps)
+	}
+	// re-adding the same shape replaces the route and its capture names
+	r.AddRoute("/orgs/:o/repos/:r/files/*", "file2")
+	if res, ps, _ := r.CallRoute("/orgs/acme/repos/api/files/main.go"); res != "file2" || ps.Get("o") != "acme" || ps.Get("org") != "" {
+		t.Fatalf("after replacing: %q %v", res, ps)
+	}
+	r.AddRoute("/plain", "plain")
+	if _, ps, _ := r.CallRoute("/plain"); ps != nil {
+		t.Fatalf("literal route captured %v", ps)
+	}
+}
+
+func BenchmarkPatternRouterCallRoute(b *testing.B) {
+	for _, n := range []int{1000, 10000, 50000} {
+		b.Run(fmt.Sprintf("routes=%d", n), func(b *testing.B) {
+			r := NewPatternRouter()
+			if err := r.Load(manyRoutes(n)); err != nil {
+				b.Fatal(err)
+			}
+			paths := []string{
+				fmt.Sprintf("/svc%d/items", n/20),
+				fmt.Sprintf("/svc%d/users/u1/items/42", n/20),
+				fmt.Sprintf("/svc%d/orders/9/lines/3", n/20),
+				fmt.Sprintf("/svc%d/files/report/meta", n/20),
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := r.CallRoute(paths[i%len(paths)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPatternRouterLoad(b *testing.B) {
+	routes := manyRoutes(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewPatternRouter().Load(routes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
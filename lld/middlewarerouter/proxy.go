@@ -0,0 +1,212 @@
+package middlewarerouter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+)
+
+// ProxyOption configures a Proxy
+type ProxyOption func(*Proxy)
+
+// WithRetries retries idempotent requests (GET, HEAD, OPTIONS, PUT, DELETE) on another
+// upstream when the connection fails or the upstream answers 502, 503 or 504
+func WithRetries(n int) ProxyOption {
+	return func(p *Proxy) {
+		p.retries = n
+	}
+}
+
+// WithRouteTimeout bounds the whole proxied request, retries included; expiry answers 504
+func WithRouteTimeout(d time.Duration) ProxyOption {
+	return func(p *Proxy) {
+		p.timeout = d
+	}
+}
+
+// WithStripPrefix removes prefix from the request path before forwarding,
+// e.g. "/api" forwards "/api/users" as "/users". Only whole segments are stripped, so
+// "/apix/users" is forwarded unchanged.
+func WithStripPrefix(prefix string) ProxyOption {
+	return func(p *Proxy) {
+		p.stripPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithRequestHeaders rewrites the headers sent upstream: set replaces values, an empty value
+// removes the header
+func WithRequestHeaders(set map[string]string) ProxyOption {
+	return func(p *Proxy) {
+		p.requestHeaders = set
+	}
+}
+
+// WithResponseHeaders rewrites the headers returned to the client, see WithRequestHeaders
+func WithResponseHeaders(set map[string]string) ProxyOption {
+	return func(p *Proxy) {
+		p.responseHeaders = set
+	}
+}
+
+// WithTransport replaces http.DefaultTransport for upstream requests
+func WithTransport(t http.RoundTripper) ProxyOption {
+	return func(p *Proxy) {
+		p.transport = t
+	}
+}
+
+// Proxy is an http.Handler forwarding requests to a Pool, so routes of an HTTPRouter can act as
+// an API gateway:
+//
+//	pool, _ := NewPool([]string{"http://10.0.0.1", "http://10.0.0.2"}, WithBalancer(LeastConnections()))
+//	r.HandleAll("/users/*path", NewProxy(pool, WithRetries(2), WithRouteTimeout(5*time.Second)))
+type Proxy struct {
+	pool            *Pool
+	retries         int
+	timeout         time.Duration
+	stripPrefix     string
+	requestHeaders  map[string]string
+	responseHeaders map[string]string
+	transport       http.RoundTripper
+	rp              *httputil.ReverseProxy
+}
+
+// maxRetryBody is the largest request body buffered so it can be replayed on retry
+const maxRetryBody = 1 << 20
+
+var errRetryableStatus = errors.New("retryable upstream status")
+
+// attempt carries per-request state through the shared ReverseProxy callbacks
+type attempt struct {
+	upstream *Upstream
+	canRetry bool // a failure may be retried, so ErrorHandler must not write a response
+	err      error
+}
+
+type attemptKey struct{}
+
+func NewProxy(pool *Pool, options ...ProxyOption) *Proxy {
+	p := &Proxy{pool: pool, transport: http.DefaultTransport}
+	for _, opt := range options {
+		opt(p)
+	}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      p.transport,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			r.Context().Value(attemptKey{}).(*attempt).err = err
+		},
+	}
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	retries := 0
+	var body []byte
+	if p.retries > 0 && idempotent(r.Method) {
+		retries = p.retries
+		if r.Body != nil && r.Body != http.NoBody {
+			b, err := io.ReadAll(io.LimitReader(r.Body, maxRetryBody+1))
+			r.Body.Close()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if len(b) > maxRetryBody {
+				retries = 0 // too big to replay, stream what we have plus the rest once
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+			} else {
+				body = b
+			}
+		}
+	}
+
+	tried := make(map[*Upstream]bool)
+	var lastErr error
+	for try := 0; try <= retries; try++ {
+		up := p.pool.pick(r, tried)
+		if up == nil {
+			break
+		}
+		tried[up] = true
+		// the last try passes the upstream's answer through, so only retry while one is left
+		a := &attempt{upstream: up, canRetry: try < retries && p.pool.untried(tried)}
+		req := r.WithContext(context.WithValue(ctx, attemptKey{}, a))
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		up.active.Add(1)
+		p.rp.ServeHTTP(w, req)
+		up.active.Add(-1)
+		if a.err == nil {
+			return
+		}
+		lastErr = a.err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+	case lastErr == nil:
+		http.Error(w, ErrNoUpstream.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	}
+}
+
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	a := pr.In.Context().Value(attemptKey{}).(*attempt)
+	if rest, ok := strings.CutPrefix(pr.Out.URL.Path, p.stripPrefix); ok && p.stripPrefix != "" &&
+		(rest == "" || rest[0] == '/') {
+		pr.Out.URL.Path = "/" + strings.TrimPrefix(rest, "/")
+		pr.Out.URL.RawPath = ""
+	}
+	pr.SetURL(a.upstream.URL)
+	pr.SetXForwarded()
+	pr.Out.Header.Set("X-Forwarded-Host", pr.In.Host)
+	rewriteHeaders(pr.Out.Header, p.requestHeaders)
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	a := resp.Request.Context().Value(attemptKey{}).(*attempt)
+	if a.canRetry && (resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout) {
+		return errRetryableStatus
+	}
+	rewriteHeaders(resp.Header, p.responseHeaders)
+	return nil
+}
+
+func rewriteHeaders(h http.Header, set map[string]string) {
+	for name, value := range set {
+		if value == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, value)
+		}
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package middlewarerouter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers every request with status and body and counts them
+func countingServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func newTestProxy(t *testing.T, targets []string, options ...ProxyOption) *Proxy {
+	t.Helper()
+	pool, err := NewPool(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProxy(pool, options...)
+}
+
+func TestProxyRetriesIdempotentRequests(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable} {
+		bad, badHits := countingServer(t, status, "bad")
+		good, goodHits := countingServer(t, http.StatusOK, "good")
+		proxy := newTestProxy(t, []string{bad.URL, good.URL}, WithRetries(1))
+
+		// round robin starts with the bad upstream
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/x", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "good" {
+			t.Fatalf("GET after %d: %d %q, want the retried 200", status, rec.Code, rec.Body)
+		}
+		if badHits.Load() != 1 || goodHits.Load() != 1 {
+			t.Fatalf("GET after %d: upstream hits %d, %d", status, badHits.Load(), goodHits.Load())
+		}
+
+		// the next round starts with the bad upstream again, but a POST is not retried
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+		badHits.Store(0)
+		goodHits.Store(0)
+		rec = httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("POST", "/x", strings.NewReader("payload")))
+		if rec.Code != status || rec.Body.String() != "bad" {
+			t.Fatalf("POST after %d: %d %q, want the upstream answer passed through", status, rec.Code, rec.Body)
+		}
+		if badHits.Load() != 1 || goodHits.Load() != 0 {
+			t.Fatalf("POST after %d: upstream hits %d, %d", status, badHits.Load(), goodHits.Load())
+		}
+	}
+}
+
+func TestProxyPassesLastAnswerThroughWhenRetriesOutnumberUpstreams(t *testing.T) {
+	first, firstHits := countingServer(t, http.StatusServiceUnavailable, "first down")
+	second, secondHits := countingServer(t, http.StatusServiceUnavailable, "second down")
+	proxy := newTestProxy(t, []string{first.URL, second.URL}, WithRetries(3))
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/x", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "second down" {
+		t.Fatalf("%d %q, want the last upstream's 503 passed through", rec.Code, rec.Body)
+	}
+	if firstHits.Load() != 1 || secondHits.Load() != 1 {
+		t.Fatalf("upstream hits %d, %d; want one each", firstHits.Load(), secondHits.Load())
+	}
+}
+
+func TestProxyRetryReplaysBody(t *testing.T) {
+	bad, _ := countingServer(t, http.StatusServiceUnavailable, "")
+	var got string
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	defer good.Close()
+	proxy := newTestProxy(t, []string{bad.URL, good.URL}, WithRetries(1))
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("PUT", "/x", strings.NewReader("payload")))
+	if rec.Code != http.StatusOK || got != "payload" {
+		t.Fatalf("PUT: %d, retried body %q", rec.Code, got)
+	}
+}
+
+func TestProxyRouteTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	proxy := newTestProxy(t, []string{slow.URL}, WithRouteTimeout(20*time.Millisecond))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timed out after %v", elapsed)
+	}
+}
+
+func TestProxyRewritesHeadersAndPath(t *testing.T) {
+	var seen *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r
+		w.Header().Set("Server", "upstream/1.0")
+		w.Header().Set("X-Internal", "secret")
+	}))
+	defer srv.Close()
+	proxy := newTestProxy(t, []string{srv.URL},
+		WithStripPrefix("/api"),
+		WithRequestHeaders(map[string]string{"X-Gateway": "gw1", "Cookie": ""}),
+		WithResponseHeaders(map[string]string{"Server": "gateway", "X-Internal": ""}),
+	)
+	req := httptest.NewRequest("GET", "http://example.com/api/users/7?full=1", nil)
+	req.Header.Set("Cookie", "session=1")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if seen.URL.Path != "/users/7" || seen.URL.RawQuery != "full=1" {
+		t.Fatalf("upstream got %s", seen.URL)
+	}
+	if seen.Header.Get("X-Gateway") != "gw1" || seen.Header.Get("Cookie") != "" {
+		t.Fatalf("upstream request headers %v", seen.Header)
+	}
+	if seen.Header.Get("X-Forwarded-Host") != "example.com" || seen.Header.Get("X-Forwarded-For") == "" {
+		t.Fatalf("upstream forwarding headers %v", seen.Header)
+	}
+	if rec.Header().Get("Server") != "gateway" || rec.Header().Get("X-Internal") != "" {
+		t.Fatalf("response headers %v", rec.Header())
+	}
+}
+
+func TestProxyStripsWholeSegments(t *testing.T) {
+	var seen string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.URL.Path
+	}))
+	defer srv.Close()
+	for _, prefix := range []string{"/api", "/api/"} {
+		proxy := newTestProxy(t, []string{srv.URL}, WithStripPrefix(prefix))
+		for path, want := range map[string]string{
+			"/api/users":  "/users",
+			"/api":        "/",
+			"/api/":       "/",
+			"/apix/users": "/apix/users",
+			"/v1/api/x":   "/v1/api/x",
+		} {
+			proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+			if seen != want {
+				t.Errorf("prefix %q: %s forwarded as %s, want %s", prefix, path, seen, want)
+			}
+		}
+	}
+}
+
+func TestProxyWithoutHealthyUpstream(t *testing.T) {
+	srv, hits := countingServer(t, http.StatusOK, "")
+	proxy := newTestProxy(t, []string{srv.URL})
+	proxy.pool.Upstreams()[0].healthy.Store(false)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || hits.Load() != 0 {
+		t.Fatalf("status %d with %d upstream hits, want 503 and none", rec.Code, hits.Load())
+	}
+}
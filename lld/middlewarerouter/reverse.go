@@ -0,0 +1,81 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// namedRoute is a pattern registered with Name, pre-parsed for URLFor
+type namedRoute struct {
+	pattern string
+	tokens  []patternToken
+}
+
+// Name gives pattern a name for URLFor and the OpenAPI export. A name refers to one pattern,
+// whatever methods are registered for it; the pattern itself need not be registered yet.
+func (r *HTTPRouter) Name(name, pattern string) error {
+	tokens, err := parsePattern(pattern)
+	if err != nil {
+		return err
+	}
+	if prev, ok := r.names[name]; ok && prev.pattern != pattern {
+		return fmt.Errorf("route name %q already used for %s", name, prev.pattern)
+	}
+	r.names[name] = &namedRoute{pattern: pattern, tokens: tokens}
+	return nil
+}
+
+// URLFor builds the path of the named route, filling in its parameters from params:
+//
+//	r.Name("user-file", "/users/:id/files/*path")
+//	r.URLFor("user-file", map[string]string{"id": "42", "path": "docs/a b.txt"})
+//	// "/users/42/files/docs/a%20b.txt"
+//
+// Values are escaped; a wildcard value keeps its slashes. It fails for an unknown name, a
+// missing or empty parameter, or a parameter the route does not have.
+func (r *HTTPRouter) URLFor(name string, params map[string]string) (string, error) {
+	nr, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	var b strings.Builder
+	used := 0
+	for _, t := range nr.tokens {
+		if t.kind == 0 {
+			b.WriteString(t.text)
+			continue
+		}
+		v, ok := params[t.text]
+		if !ok || (v == "" && t.kind == ':') {
+			return "", fmt.Errorf("route %q: missing parameter %q", name, t.text)
+		}
+		used++
+		if t.kind == ':' {
+			b.WriteString(url.PathEscape(v))
+			continue
+		}
+		segs := strings.Split(v, "/")
+		for i, s := range segs {
+			segs[i] = url.PathEscape(s)
+		}
+		b.WriteString(strings.Join(segs, "/"))
+	}
+	if used != len(params) {
+		for key := range params {
+			if !hasParam(nr.tokens, key) {
+				return "", fmt.Errorf("route %q has no parameter %q", name, key)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func hasParam(tokens []patternToken, key string) bool {
+	for _, t := range tokens {
+		if t.kind != 0 && t.text == key {
+			return true
+		}
+	}
+	return false
+}
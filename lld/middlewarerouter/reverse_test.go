@@ -0,0 +1,138 @@
+package middlewarerouter
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestURLFor(t *testing.T) {
+	r := NewHTTPRouter()
+	for name, pattern := range map[string]string{
+		"user":      "/users/:id",
+		"user-file": "/users/:id/files/*path",
+		"health":    "/health",
+	} {
+		if err := r.Name(name, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		want   string
+	}{
+		{"health", nil, "/health"},
+		{"user", map[string]string{"id": "42"}, "/users/42"},
+		// a parameter value is one segment, so its slashes are escaped
+		{"user", map[string]string{"id": "a/b c"}, "/users/a%2Fb%20c"},
+		{"user", map[string]string{"id": "x?y#z"}, "/users/x%3Fy%23z"},
+		// a wildcard keeps its slashes and escapes each segment
+		{"user-file", map[string]string{"id": "7", "path": "docs/a b.txt"}, "/users/7/files/docs/a%20b.txt"},
+		{"user-file", map[string]string{"id": "7", "path": "100%/ü?"}, "/users/7/files/100%25/%C3%BC%3F"},
+		{"user-file", map[string]string{"id": "7", "path": ""}, "/users/7/files/"},
+	} {
+		got, err := r.URLFor(tc.name, tc.params)
+		if err != nil || got != tc.want {
+			t.Errorf("URLFor(%s, %v) = %q, %v; want %q", tc.name, tc.params, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]string
+		err    string
+	}{
+		{"nope", nil, `no route named "nope"`},
+		{"user", nil, `missing parameter "id"`},
+		{"user", map[string]string{"id": ""}, `missing parameter "id"`},
+		{"user-file", map[string]string{"id": "7"}, `missing parameter "path"`},
+		{"user", map[string]string{"id": "7", "tab": "x"}, `has no parameter "tab"`},
+		{"health", map[string]string{"id": "7"}, `has no parameter "id"`},
+	} {
+		if got, err := r.URLFor(tc.name, tc.params); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("URLFor(%s, %v) = %q, %v; want an error containing %q", tc.name, tc.params, got, err, tc.err)
+		}
+	}
+
+	// a URL built for a route routes back to it with the same parameters
+	r.HandleFunc("GET", "/users/:id/files/*path", echoRoute)
+	u, _ := r.URLFor("user-file", map[string]string{"id": "a b", "path": "x/y z"})
+	if rec := get(r, u); rec.Body.String() != "/users/:id/files/*path id=a b path=x/y z" {
+		t.Errorf("GET %s = %q", u, rec.Body)
+	}
+}
+
+func TestNameConflicts(t *testing.T) {
+	r := NewHTTPRouter()
+	if err := r.Name("user", "/users/:id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Name("user", "/users/:id"); err != nil {
+		t.Errorf("renaming the same pattern: %v", err)
+	}
+	if err := r.Name("user", "/people/:id"); err == nil {
+		t.Error("one name given to two patterns")
+	}
+	if err := r.Name("bad", "/files/*path/meta"); err == nil {
+		t.Error("malformed pattern named")
+	}
+	g := r.Group("/api/v1")
+	if err := g.Name("v1-user", "/users/:id"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := r.URLFor("v1-user", map[string]string{"id": "7"}); err != nil || u != "/api/v1/users/7" {
+		t.Errorf("URLFor of a group route = %q, %v", u, err)
+	}
+}
+
+func TestOpenAPIGolden(t *testing.T) {
+	r := NewHTTPRouter()
+	h := http.HandlerFunc(echoRoute)
+	r.AddRoute("GET", "/health", h)
+	r.AddRoute("GET", "/users/:id", h)
+	r.AddRoute("PUT", "/users/:id", h)
+	r.AddRoute("delete", "/users/:id", h)
+	r.AddRoute("GET", "/users/:id", h, Header("X-Canary", "true")) // same operation, not listed twice
+	r.AddRoute("CONNECT", "/tunnel", h)                            // not describable in OpenAPI
+	api := r.Group("/api/v1")
+	api.AddRoute("GET", "/orgs/:org/files/*path", h)
+	api.AddRoute("POST", "/orgs/:org/files/*path", h)
+	for name, pattern := range map[string]string{"user": "/users/:id", "health": "/health"} {
+		if err := r.Name(name, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := api.Name("org-file", "/orgs/:org/files/*path"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.OpenAPI("Gateway", "1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "openapi.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, append(got, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(got, '\n'), want) {
+		t.Fatalf("OpenAPI output differs from %s (run with -update to accept):\n%s", golden, got)
+	}
+
+	again, _ := r.OpenAPI("Gateway", "1.2.0")
+	if !bytes.Equal(got, again) {
+		t.Fatal("OpenAPI output is not deterministic")
+	}
+}
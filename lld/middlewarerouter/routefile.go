@@ -0,0 +1,118 @@
+package middlewarerouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RouteEntry is one route of a route file
+type RouteEntry struct {
+	Path   string `json:"path"`
+	Result string `json:"result"`
+}
+
+// RouteFile is the content of a route file, e.g. in YAML:
+//
+//	routes:
+//	  - path: /users/:id
+//	    result: user-service
+//	  - path: /static/*/logo.png
+//	    result: cdn
+type RouteFile struct {
+	Routes []RouteEntry `json:"routes"`
+}
+
+// RouteLoader is a route table that can be replaced as a whole, like SimpleRouter and PatternRouter
+type RouteLoader interface {
+	Load(routes []RouteEntry) error
+}
+
+// ParseRouteFile decodes a route file; format is "json" or "yaml"
+func ParseRouteFile(data []byte, format string) ([]RouteEntry, error) {
+	switch strings.ToLower(format) {
+	case "json":
+	case "yaml", "yml":
+		// decode YAML generically and re-encode as JSON so both formats share one schema
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("route file: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("route file: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("route file: unknown format %q", format)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var f RouteFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("route file: %w", err)
+	}
+	for i, e := range f.Routes {
+		if e.Path == "" {
+			return nil, fmt.Errorf("route file: route %d has no path", i)
+		}
+	}
+	return f.Routes, nil
+}
+
+// LoadRouteFile reads a route file, the format is taken from its extension (.json, .yaml, .yml)
+func LoadRouteFile(path string) ([]RouteEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRouteFile(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// WatchRouteFile loads path into table now and again whenever it changes, polling every
+// interval until ctx is done. An invalid file is reported to onError (may be nil) and the
+// live table keeps serving the last good version.
+func WatchRouteFile(ctx context.Context, path string, interval time.Duration, table RouteLoader, onError func(error)) error {
+	routes, err := LoadRouteFile(path)
+	if err == nil {
+		err = table.Load(routes)
+	}
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	lastMod, lastSize := fi.ModTime(), fi.Size()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(path)
+		if err == nil && fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		if err == nil {
+			lastMod, lastSize = fi.ModTime(), fi.Size()
+			var routes []RouteEntry
+			if routes, err = LoadRouteFile(path); err == nil {
+				err = table.Load(routes)
+			}
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package middlewarerouter
 
 import (
 	"fmt"
+	"maps"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Router interface defines the middleware router methods
@@ -11,25 +14,66 @@ type Router interface {
 	CallRoute(path string) (string, error)
 }
 
-// SimpleRouter struct will implement the Router interface
+// SimpleRouter struct will implement the Router interface.
+// The route map is copy-on-write: writers build a new map and swap it in, so CallRoute is
+// lock-free and never sees a half-applied update.
 type SimpleRouter struct {
-	routes map[string]string
+	mu     sync.Mutex // serializes writers
+	routes atomic.Pointer[map[string]string]
 }
 
 // NewRouter creates a new instance of SimpleRouter
 func NewRouter() *SimpleRouter {
-	return &SimpleRouter{
-		routes: make(map[string]string),
-	}
+	r := &SimpleRouter{}
+	r.routes.Store(&map[string]string{})
+	return r
 }
 
 // AddRoute adds a new route and its associated result
 func (r *SimpleRouter) AddRoute(path string, result string) {
-	// Normalize the path by ensuring it starts with a "/"
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+	path = normalizePath(path)
+	r.update(func(routes map[string]string) bool {
+		routes[path] = result
+		return true
+	})
+}
+
+// RemoveRoute removes a route and reports whether it existed
+func (r *SimpleRouter) RemoveRoute(path string) bool {
+	path = normalizePath(path)
+	return r.update(func(routes map[string]string) bool {
+		_, ok := routes[path]
+		delete(routes, path)
+		return ok
+	})
+}
+
+// Load replaces all routes at once. Duplicate paths are rejected and leave the table untouched.
+func (r *SimpleRouter) Load(routes []RouteEntry) error {
+	next := make(map[string]string, len(routes))
+	for _, e := range routes {
+		path := normalizePath(e.Path)
+		if _, ok := next[path]; ok {
+			return fmt.Errorf("duplicate route %q", path)
+		}
+		next[path] = e.Result
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes.Store(&next)
+	return nil
+}
+
+// update applies fn to a copy of the routes and publishes it if fn reports a change
+func (r *SimpleRouter) update(fn func(routes map[string]string) bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := maps.Clone(*r.routes.Load())
+	if !fn(next) {
+		return false
 	}
-	r.routes[path] = result
+	r.routes.Store(&next)
+	return true
 }
 
 // CallRoute calls a route and returns its associated result
@@ -40,7 +84,7 @@ func (r *SimpleRouter) CallRoute(path string) (string, error) {
 	}
 
 	// Check if the path exists in the routes map
-	if result, exists := r.routes[path]; exists {
+	if result, exists := (*r.routes.Load())[path]; exists {
 		return result, nil
 	}
 
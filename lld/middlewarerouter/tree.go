@@ -0,0 +1,215 @@
+package middlewarerouter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node is a radix tree node. Static edges are compressed (prefix holds the bytes shared by all
+// routes below), while parameters and wildcards get dedicated children so lookup can try them
+// in precedence order: static, then ":param", then "*wildcard".
+type node struct {
+	prefix   string
+	indices  string  // first byte of each static child, in the same order as children
+	children []*node // static children
+	param    *node   // ":name" child, matches one non-empty segment
+	wildcard *node   // "*name" child, matches the rest of the path
+	name     string  // parameter name of a param or wildcard node
+	value    any     // route payload, nil when no route ends here
+	pattern  string
+}
+
+// Param is one path parameter captured by a route
+type Param struct {
+	Key, Value string
+}
+
+// Params are the parameters of a matched route, in path order
+type Params []Param
+
+// Get returns the value of the named parameter, "" if the route has none
+func (ps Params) Get(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// patternToken is a static piece, or a parameter when kind is ':' or '*'
+type patternToken struct {
+	kind byte
+	text string
+}
+
+// parsePattern splits a route pattern such as "/users/:id/files/*path" into tokens.
+// Parameters must span a whole segment and a wildcard must be the last segment.
+func parsePattern(pattern string) ([]patternToken, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern %q must start with '/'", pattern)
+	}
+	var tokens []patternToken
+	static := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != ':' && c != '*' {
+			continue
+		}
+		if pattern[i-1] != '/' {
+			return nil, fmt.Errorf("pattern %q: %c must start a segment", pattern, c)
+		}
+		end := strings.IndexByte(pattern[i:], '/')
+		if end < 0 {
+			end = len(pattern)
+		} else {
+			end += i
+		}
+		name := pattern[i+1 : end]
+		if name == "" || strings.ContainsAny(name, ":*") {
+			return nil, fmt.Errorf("pattern %q: bad parameter name %q", pattern, name)
+		}
+		if c == '*' && end != len(pattern) {
+			return nil, fmt.Errorf("pattern %q: wildcard must be the last segment", pattern)
+		}
+		tokens = append(tokens, patternToken{text: pattern[static:i]}, patternToken{kind: c, text: name})
+		static, i = end, end-1
+	}
+	if static < len(pattern) {
+		tokens = append(tokens, patternToken{text: pattern[static:]})
+	}
+	return tokens, nil
+}
+
+// insert returns the node for pattern, creating the path to it. Two patterns that name the same
+// parameter position differently (e.g. "/users/:id" and "/users/:name/posts") conflict.
+func (n *node) insert(pattern string) (*node, error) {
+	tokens, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch t.kind {
+		case ':':
+			if n.param == nil {
+				n.param = &node{name: t.text}
+			} else if n.param.name != t.text {
+				return nil, fmt.Errorf("pattern %q: parameter :%s conflicts with :%s of %s",
+					pattern, t.text, n.param.name, n.param.somePattern())
+			}
+			n = n.param
+		case '*':
+			if n.wildcard == nil {
+				n.wildcard = &node{name: t.text}
+			} else if n.wildcard.name != t.text {
+				return nil, fmt.Errorf("pattern %q: wildcard *%s conflicts with *%s of %s",
+					pattern, t.text, n.wildcard.name, n.wildcard.somePattern())
+			}
+			n = n.wildcard
+		default:
+			n = n.staticChild(t.text)
+		}
+	}
+	n.pattern = pattern
+	return n, nil
+}
+
+// staticChild returns the node reached by the static string s below n, splitting edges as needed
+func (n *node) staticChild(s string) *node {
+	for s != "" {
+		i := strings.IndexByte(n.indices, s[0])
+		if i < 0 {
+			child := &node{prefix: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		child := n.children[i]
+		common := commonPrefix(child.prefix, s)
+		if common < len(child.prefix) {
+			mid := &node{prefix: child.prefix[:common], indices: child.prefix[common : common+1], children: []*node{child}}
+			child.prefix = child.prefix[common:]
+			n.children[i] = mid
+			child = mid
+		}
+		n, s = child, s[common:]
+	}
+	return n
+}
+
+// lookup matches path (what remains after n's prefix) below n, appending captured parameters
+// to ps. Only nodes with a value that accept agrees to are matches. It backtracks, so
+// "/users/new" can fall back to "/users/:id" when the static branch has no accepted route for
+// the rest of the path.
+func (n *node) lookup(path string, ps *Params, accept func(*node) bool) *node {
+	if path == "" && n.value != nil && accept(n) {
+		return n
+	}
+	if path != "" {
+		if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+			child := n.children[i]
+			if strings.HasPrefix(path, child.prefix) {
+				if found := child.lookup(path[len(child.prefix):], ps, accept); found != nil {
+					return found
+				}
+			}
+		}
+		if n.param != nil {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			if end > 0 {
+				*ps = append(*ps, Param{Key: n.param.name, Value: path[:end]})
+				if found := n.param.lookup(path[end:], ps, accept); found != nil {
+					return found
+				}
+				*ps = (*ps)[:len(*ps)-1]
+			}
+		}
+	}
+	if n.wildcard != nil && n.wildcard.value != nil && accept(n.wildcard) {
+		*ps = append(*ps, Param{Key: n.wildcard.name, Value: path})
+		return n.wildcard
+	}
+	return nil
+}
+
+// walk calls fn for every node below n holding a route
+func (n *node) walk(fn func(n *node)) {
+	if n.value != nil {
+		fn(n)
+	}
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+	if n.param != nil {
+		n.param.walk(fn)
+	}
+	if n.wildcard != nil {
+		n.wildcard.walk(fn)
+	}
+}
+
+// somePattern names a route below n for conflict messages
+func (n *node) somePattern() string {
+	if n.value != nil {
+		return n.pattern
+	}
+	for _, c := range append(append([]*node{}, n.children...), n.param, n.wildcard) {
+		if c != nil {
+			if p := c.somePattern(); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,194 @@
+package rate_limiter
+
+import (
+	"math"
+	"time"
+)
+
+// Sample is the outcome of one permit
+type Sample struct {
+	Start    time.Time // when the permit was acquired
+	RTT      time.Duration
+	InFlight int  // requests in flight when the permit was acquired, including itself
+	Dropped  bool // failed from overload, see Permit.Drop
+}
+
+// LimitAlgorithm decides the concurrency limit from samples. The limiter serializes calls,
+// so implementations need no locking of their own.
+type LimitAlgorithm interface {
+	Limit() int
+	// Update records a sample and returns the new limit
+	Update(s Sample) int
+}
+
+// ------------------- fixed ------------------
+
+type fixedLimit struct{ limit int }
+
+// NewFixedLimit never changes the limit. Limits below 1 are raised to 1 so permits can be granted.
+func NewFixedLimit(limit int) LimitAlgorithm { return &fixedLimit{limit: max(limit, 1)} }
+
+func (l *fixedLimit) Limit() int        { return l.limit }
+func (l *fixedLimit) Update(Sample) int { return l.limit }
+
+// ------------------- AIMD ------------------
+
+// aimdLimit grows the limit by one per round trip (1/limit per successful sample) and multiplies
+// it by backoff on a drop or a response slower than timeout, like TCP congestion control.
+// Like TCP it backs off at most once per round trip: overload reported by requests that
+// started before the last backoff was already accounted for.
+type aimdLimit struct {
+	limit       float64
+	min, max    int
+	backoff     float64
+	timeout     time.Duration
+	lastBackoff time.Time
+}
+
+// NewAIMDLimit starts at initial and stays within [min, max], see limitBounds. backoff in (0,1)
+// is the factor applied on overload (0.9 is typical); timeout 0 only treats drops as overload.
+func NewAIMDLimit(initial, min, max int, backoff float64, timeout time.Duration) LimitAlgorithm {
+	limit, min, max := limitBounds(initial, min, max)
+	return &aimdLimit{limit: limit, min: min, max: max, backoff: backoff, timeout: timeout}
+}
+
+func (l *aimdLimit) Limit() int { return int(l.limit) }
+
+func (l *aimdLimit) Update(s Sample) int {
+	switch {
+	case s.Dropped || (l.timeout > 0 && s.RTT > l.timeout):
+		if s.Start.Before(l.lastBackoff) {
+			break
+		}
+		l.limit *= l.backoff
+		l.lastBackoff = s.Start.Add(s.RTT)
+	case s.InFlight*2 >= int(l.limit):
+		// only grow while the limit is actually in use, an idle service proves nothing
+		l.limit += 1 / l.limit
+	}
+	l.limit = clampLimit(l.limit, l.min, l.max)
+	return int(l.limit)
+}
+
+// ------------------- Vegas ------------------
+
+// vegasLimit estimates the queue building up at the downstream from how far the latency is
+// above the no-load latency: queue = limit * (1 - rttNoLoad/rtt). It grows the limit while the
+// queue is below alpha and shrinks it above beta, both scaled by log10(limit), in steps of
+// log10(limit).
+type vegasLimit struct {
+	limit    float64
+	min, max int
+	noLoad   minRTT
+}
+
+// NewVegasLimit starts at initial and stays within [min, max], see limitBounds
+func NewVegasLimit(initial, min, max int) LimitAlgorithm {
+	limit, min, max := limitBounds(initial, min, max)
+	return &vegasLimit{limit: limit, min: min, max: max}
+}
+
+func (l *vegasLimit) Limit() int { return int(l.limit) }
+
+func (l *vegasLimit) Update(s Sample) int {
+	if s.RTT <= 0 {
+		return int(l.limit)
+	}
+	rttNoLoad := l.noLoad.add(s.RTT)
+
+	logLimit := max(math.Log10(l.limit), 1)
+	alpha, beta := 3*logLimit, 6*logLimit
+	queue := math.Ceil(l.limit * (1 - float64(rttNoLoad)/float64(s.RTT)))
+	switch {
+	case s.Dropped:
+		l.limit -= logLimit
+	case s.InFlight*2 < int(l.limit):
+		// app-limited, the sample says nothing about capacity
+	case queue < alpha:
+		l.limit += logLimit
+	case queue > beta:
+		l.limit -= logLimit
+	}
+	l.limit = clampLimit(l.limit, l.min, l.max)
+	return int(l.limit)
+}
+
+// ------------------- Gradient ------------------
+
+// gradientLimit scales the limit by gradient = tolerance * rttNoLoad / rtt, clamped to
+// [0.5, 1], where rtt is a short moving average, and adds a queue allowance of sqrt(limit).
+// While latency stays within tolerance of the no-load latency the limit grows by the allowance;
+// beyond it the limit shrinks in proportion to the slowdown. Changes are smoothed so a single
+// slow response does not halve the limit.
+type gradientLimit struct {
+	limit     float64
+	min, max  int
+	noLoad    minRTT
+	shortRTT  float64 // exponential average over ~10 samples
+	smoothing float64
+	tolerance float64
+}
+
+// NewGradientLimit starts at initial and stays within [min, max], see limitBounds
+func NewGradientLimit(initial, min, max int) LimitAlgorithm {
+	limit, min, max := limitBounds(initial, min, max)
+	return &gradientLimit{limit: limit, min: min, max: max, smoothing: 0.2, tolerance: 1.5}
+}
+
+func (l *gradientLimit) Limit() int { return int(l.limit) }
+
+func (l *gradientLimit) Update(s Sample) int {
+	if s.RTT <= 0 {
+		return int(l.limit)
+	}
+	rttNoLoad := float64(l.noLoad.add(s.RTT))
+	if l.shortRTT == 0 {
+		l.shortRTT = float64(s.RTT)
+	}
+	l.shortRTT += (float64(s.RTT) - l.shortRTT) / 10
+	if s.InFlight*2 < int(l.limit) && !s.Dropped {
+		return int(l.limit)
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.tolerance*rttNoLoad/l.shortRTT))
+	if s.Dropped {
+		gradient = 0.5
+	}
+	target := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = clampLimit(l.limit*(1-l.smoothing)+target*l.smoothing, l.min, l.max)
+	return int(l.limit)
+}
+
+// minRTT tracks the no-load latency as the lowest RTT of the current and the previous window of
+// minRTTWindow samples, so it follows the downstream when its baseline latency rises for good
+type minRTT struct {
+	prev, cur time.Duration
+	n         int
+}
+
+const minRTTWindow = 1000
+
+func (m *minRTT) add(rtt time.Duration) time.Duration {
+	if m.cur == 0 || rtt < m.cur {
+		m.cur = rtt
+	}
+	if m.n++; m.n == minRTTWindow {
+		m.prev, m.cur, m.n = m.cur, 0, 0
+	}
+	if m.prev != 0 && m.prev < m.cur || m.cur == 0 {
+		return m.prev
+	}
+	return m.cur
+}
+
+// limitBounds raises lo to at least 1 and hi to at least lo, and moves initial between them.
+// A limit of 0 would never grant a permit again, and the algorithms divide by the limit.
+func limitBounds(initial, lo, hi int) (float64, int, int) {
+	lo = max(lo, 1)
+	hi = max(hi, lo)
+	return clampLimit(float64(initial), lo, hi), lo, hi
+}
+
+func clampLimit(limit float64, min, max int) float64 {
+	return math.Max(float64(min), math.Min(float64(max), limit))
+}
@@ -0,0 +1,90 @@
+package rate_limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// backend is a simulated downstream with a number of workers each taking service to answer.
+// Requests beyond the workers queue, so latency grows with the load.
+type backend struct {
+	workers int
+	service time.Duration
+}
+
+func (b backend) latency(inFlight int) time.Duration {
+	return b.service * time.Duration(max(inFlight, b.workers)) / time.Duration(b.workers)
+}
+
+// saturate feeds algo n samples from a client with unbounded demand, which always has as many
+// requests in flight as the limit allows. It returns the clock after the last sample.
+func saturate(algo LimitAlgorithm, b backend, now time.Time, n int) time.Time {
+	for i := 0; i < n; i++ {
+		inFlight := algo.Limit()
+		rtt := b.latency(inFlight)
+		algo.Update(Sample{Start: now, RTT: rtt, InFlight: inFlight})
+		// inFlight requests complete every rtt
+		now = now.Add(rtt / time.Duration(inFlight))
+	}
+	return now
+}
+
+func TestAdaptiveLimitsFollowLatency(t *testing.T) {
+	healthy := backend{workers: 40, service: 10 * time.Millisecond}
+	degraded := backend{workers: 10, service: 50 * time.Millisecond}
+	for name, algo := range map[string]LimitAlgorithm{
+		"aimd":     NewAIMDLimit(10, 1, 200, 0.9, 50*time.Millisecond),
+		"vegas":    NewVegasLimit(10, 1, 200),
+		"gradient": NewGradientLimit(10, 1, 200),
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(1_700_000_000, 0)
+			now = saturate(algo, healthy, now, 500)
+			before := algo.Limit()
+			if before < healthy.workers/2 {
+				t.Fatalf("limit %d against a healthy backend, want it to grow towards %d workers", before, healthy.workers)
+			}
+
+			now = saturate(algo, degraded, now, 300)
+			during := algo.Limit()
+			if during > before/2 {
+				t.Fatalf("limit %d with rising latency, want at most half of %d", during, before)
+			}
+
+			saturate(algo, healthy, now, 500)
+			if after := algo.Limit(); after < 2*during || after < healthy.workers/2 {
+				t.Fatalf("limit %d after recovery, want it back above %d and %d", after, 2*during, healthy.workers/2)
+			}
+			t.Logf("limit %d healthy, %d degraded, %d recovered", before, during, algo.Limit())
+		})
+	}
+}
+
+func TestLimitsStayPositive(t *testing.T) {
+	for name, algo := range map[string]LimitAlgorithm{
+		"fixed":    NewFixedLimit(0),
+		"aimd":     NewAIMDLimit(0, 0, 0, 0.5, time.Millisecond),
+		"vegas":    NewVegasLimit(0, -1, 0),
+		"gradient": NewGradientLimit(-5, 0, 0),
+	} {
+		if got := algo.Limit(); got != 1 {
+			t.Errorf("%s: initial limit %d, want 1", name, got)
+		}
+		for i := 0; i < 10; i++ {
+			algo.Update(Sample{Start: time.Unix(int64(i), 0), RTT: time.Second, InFlight: 1, Dropped: i%2 == 0})
+		}
+		if got := algo.Limit(); got != 1 {
+			t.Errorf("%s: limit %d after overload, want 1", name, got)
+		}
+	}
+
+	cl := NewConcurrencyLimiter(0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p, err := cl.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire on NewConcurrencyLimiter(0): %v", err)
+	}
+	p.Release()
+}
@@ -0,0 +1,173 @@
+package rate_limiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimiter bounds the work in flight instead of the request rate, which keeps
+// protecting a downstream when it slows down: slower responses hold permits longer and fewer
+// new requests get in. The limit may be fixed or adapted from observed latency, see LimitAlgorithm.
+type ConcurrencyLimiter interface {
+	// Acquire blocks until a permit is available or ctx is done
+	Acquire(ctx context.Context) (*Permit, error)
+	// TryAcquire returns a permit only if one is available right now
+	TryAcquire() (*Permit, bool)
+	Limit() int
+	InFlight() int
+}
+
+// Permit is one unit of in-flight work. Exactly one of Release, Drop or Ignore must be called;
+// later calls are no-ops.
+type Permit struct {
+	cl       *concurrencyLimiter
+	start    time.Time
+	inFlight int
+	done     atomic.Bool
+}
+
+// Release ends the work as a success and feeds its latency to the limit algorithm
+func (p *Permit) Release() { p.finish(false, true) }
+
+// Drop ends the work as a failure caused by overload (timeout, 503, rejected), which adaptive
+// algorithms answer by backing off
+func (p *Permit) Drop() { p.finish(true, true) }
+
+// Ignore ends the work without a sample, e.g. for client errors unrelated to downstream load
+func (p *Permit) Ignore() { p.finish(false, false) }
+
+func (p *Permit) finish(dropped, sample bool) {
+	if p.done.Swap(true) {
+		return
+	}
+	p.cl.release(p, dropped, sample)
+}
+
+// ConcurrencyOption configures a concurrency limiter
+type ConcurrencyOption func(*concurrencyLimiter)
+
+// WithConcurrencyClock replaces time.Now for latency measurement, e.g. with a simulated clock
+func WithConcurrencyClock(now func() time.Time) ConcurrencyOption {
+	return func(cl *concurrencyLimiter) {
+		cl.now = now
+	}
+}
+
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	algo     LimitAlgorithm
+	limit    int
+	inFlight int
+	waiters  *list.List // of chan struct{}, FIFO
+	now      func() time.Time
+}
+
+// NewConcurrencyLimiter allows at most limit requests in flight
+func NewConcurrencyLimiter(limit int, options ...ConcurrencyOption) ConcurrencyLimiter {
+	return NewAdaptiveLimiter(NewFixedLimit(limit), options...)
+}
+
+// NewAdaptiveLimiter lets algo move the in-flight limit from the samples of released permits.
+// The limit never goes below 1, whatever algo reports.
+func NewAdaptiveLimiter(algo LimitAlgorithm, options ...ConcurrencyOption) ConcurrencyLimiter {
+	cl := &concurrencyLimiter{
+		algo:    algo,
+		limit:   max(algo.Limit(), 1),
+		waiters: list.New(),
+		now:     time.Now,
+	}
+	for _, opt := range options {
+		opt(cl)
+	}
+	return cl
+}
+
+func (cl *concurrencyLimiter) TryAcquire() (*Permit, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.inFlight >= cl.limit || cl.waiters.Len() > 0 {
+		return nil, false
+	}
+	return cl.grant(), true
+}
+
+func (cl *concurrencyLimiter) Acquire(ctx context.Context) (*Permit, error) {
+	cl.mu.Lock()
+	if cl.inFlight < cl.limit && cl.waiters.Len() == 0 {
+		p := cl.grant()
+		cl.mu.Unlock()
+		return p, nil
+	}
+	ready := make(chan struct{})
+	elem := cl.waiters.PushBack(ready)
+	cl.mu.Unlock()
+
+	select {
+	case <-ready:
+		// release counted us in flight when it handed over the slot
+		cl.mu.Lock()
+		p := cl.permit()
+		cl.mu.Unlock()
+		return p, nil
+	case <-ctx.Done():
+		cl.mu.Lock()
+		defer cl.mu.Unlock()
+		select {
+		case <-ready:
+			// granted while we were giving up, pass the slot on
+			cl.inFlight--
+			cl.wakeLocked()
+		default:
+			cl.waiters.Remove(elem)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (cl *concurrencyLimiter) Limit() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.limit
+}
+
+func (cl *concurrencyLimiter) InFlight() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.inFlight
+}
+
+// grant counts a new request in flight. Caller must hold mu.
+func (cl *concurrencyLimiter) grant() *Permit {
+	cl.inFlight++
+	return cl.permit()
+}
+
+func (cl *concurrencyLimiter) permit() *Permit {
+	return &Permit{cl: cl, start: cl.now(), inFlight: cl.inFlight}
+}
+
+func (cl *concurrencyLimiter) release(p *Permit, dropped, sample bool) {
+	var rtt time.Duration
+	if sample {
+		rtt = cl.now().Sub(p.start)
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.inFlight--
+	if sample {
+		cl.limit = max(cl.algo.Update(Sample{Start: p.start, RTT: rtt, InFlight: p.inFlight, Dropped: dropped}), 1)
+	}
+	cl.wakeLocked()
+}
+
+// wakeLocked hands free slots to waiters in arrival order. Caller must hold mu.
+func (cl *concurrencyLimiter) wakeLocked() {
+	for cl.inFlight < cl.limit && cl.waiters.Len() > 0 {
+		ready := cl.waiters.Remove(cl.waiters.Front()).(chan struct{})
+		cl.inFlight++
+		close(ready)
+	}
+}
@@ -0,0 +1,404 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config declares rate limits, e.g. in YAML:
+//
+//	rules:
+//	  - name: per-user
+//	    algorithm: token_bucket
+//	    limit: 100
+//	    period: 1m
+//	    key: [user]
+//	  - name: per-tenant
+//	    algorithm: sliding_window_counter
+//	    limit: 10000
+//	    period: 1m
+//	    key: [tenant]
+//	    overrides:
+//	      - match: {tenant: acme}
+//	        limit: 50000
+//
+// Every rule whose key attributes are present on a request must allow it, see PolicyEngine.
+type Config struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// RuleConfig is one limit: limit units per period for each distinct value of the key attributes
+type RuleConfig struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"` // one of Algorithms
+	Limit     int    `json:"limit"`
+	// Period is a Go duration string such as "1s" or "1m"
+	Period Duration `json:"period"`
+	// Burst is the queue capacity of a leaky_bucket, defaults to Limit. Other algorithms reject it.
+	Burst int `json:"burst,omitempty"`
+	// Key lists the request attributes the limit is counted per; empty means one global bucket
+	Key       []string         `json:"key,omitempty"`
+	Overrides []OverrideConfig `json:"overrides,omitempty"`
+}
+
+// OverrideConfig replaces a rule's limit for requests whose attributes all equal Match,
+// e.g. a bigger quota for one tenant. The first matching override wins.
+type OverrideConfig struct {
+	Match  map[string]string `json:"match"`
+	Limit  int               `json:"limit,omitempty"`  // defaults to the rule's
+	Period Duration          `json:"period,omitempty"` // defaults to the rule's
+	Burst  int               `json:"burst,omitempty"`  // defaults to the rule's
+}
+
+// Duration is a time.Duration written as a string like "1m30s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1m\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Algorithms maps config algorithm names to limiter constructors
+var Algorithms = map[string]func(limit int, period time.Duration, burst int, options ...Option) (RateLimiter, error){
+	"token_bucket": func(limit int, period time.Duration, _ int, options ...Option) (RateLimiter, error) {
+		return NewTokenBucketRatelimiter(limit, period, options...), nil
+	},
+	"leaky_bucket": func(limit int, period time.Duration, burst int, options ...Option) (RateLimiter, error) {
+		return newLeakyBucketRatelimiter(burst, float64(limit)/period.Seconds(), options...), nil
+	},
+	"fixed_window": func(limit int, period time.Duration, _ int, options ...Option) (RateLimiter, error) {
+		return NewFixedWindowRatelimiter(append(options, WithMaxRequests(limit), WithWindowSize(period))...), nil
+	},
+	"sliding_window": func(limit int, period time.Duration, _ int, options ...Option) (RateLimiter, error) {
+		return NewSlidingWindowRatelimiter(limit, period, options...), nil
+	},
+	"sliding_window_counter": func(limit int, period time.Duration, _ int, options ...Option) (RateLimiter, error) {
+		rl, err := NewSlidingWindowCounterRatelimiter(limit, period, options...)
+		if err != nil {
+			return nil, err
+		}
+		return rl, nil
+	},
+	"gcra": func(limit int, period time.Duration, _ int, options ...Option) (RateLimiter, error) {
+		rl, err := NewGCRARatelimiter(limit, period, options...)
+		if err != nil {
+			return nil, err
+		}
+		return rl, nil
+	},
+}
+
+// ParseConfig decodes and validates a config; format is "json" or "yaml"
+func ParseConfig(data []byte, format string) (*Config, error) {
+	switch strings.ToLower(format) {
+	case "json":
+	case "yaml", "yml":
+		// decode YAML generically and re-encode as JSON so both formats share one schema
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("rate limit config: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("rate limit config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("rate limit config: unknown format %q", format)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("rate limit config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadConfig reads a config file, the format is taken from its extension (.json, .yaml, .yml)
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// Validate reports the first invalid rule
+func (cfg *Config) Validate() error {
+	names := make(map[string]bool)
+	for i, r := range cfg.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rate limit config: rule %d has no name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rate limit config: duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+		if _, err := r.build(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compiledRule is one bucket family of a rule: the default limit or one override
+type compiledRule struct {
+	rule        Rule
+	fingerprint string
+	newLimiter  func(options ...Option) (RateLimiter, error)
+}
+
+// build expands a rule into its overrides, most specific first, followed by the default.
+// Only the first applicable one is enforced for a request.
+func (r RuleConfig) build() ([]compiledRule, error) {
+	newLimiter, ok := Algorithms[r.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("rate limit config: rule %q: unknown algorithm %q", r.Name, r.Algorithm)
+	}
+	variant := func(name string, limit int, period time.Duration, burst int, key KeyFunc) (compiledRule, error) {
+		if limit <= 0 || period <= 0 {
+			return compiledRule{}, fmt.Errorf("rate limit config: rule %q: limit and period must be positive", name)
+		}
+		if burst != 0 && r.Algorithm != "leaky_bucket" {
+			return compiledRule{}, fmt.Errorf("rate limit config: rule %q: burst only applies to leaky_bucket", name)
+		}
+		if burst == 0 {
+			burst = limit
+		}
+		if _, err := newLimiter(limit, period, burst, WithStore(nopStore{})); err != nil {
+			return compiledRule{}, fmt.Errorf("rate limit config: rule %q: %w", name, err)
+		}
+		return compiledRule{
+			rule:        Rule{Name: name, Key: key},
+			fingerprint: fmt.Sprintf("%s/%d/%d/%d", r.Algorithm, limit, period, burst),
+			newLimiter: func(options ...Option) (RateLimiter, error) {
+				return newLimiter(limit, period, burst, options...)
+			},
+		}, nil
+	}
+
+	base := r.keyFunc()
+	var out []compiledRule
+	for i, ov := range r.Overrides {
+		if len(ov.Match) == 0 {
+			return nil, fmt.Errorf("rate limit config: rule %q: override %d has no match", r.Name, i)
+		}
+		limit, period, burst := r.Limit, time.Duration(r.Period), r.Burst
+		if ov.Limit != 0 {
+			limit = ov.Limit
+		}
+		if ov.Period != 0 {
+			period = time.Duration(ov.Period)
+		}
+		if ov.Burst != 0 {
+			burst = ov.Burst
+		}
+		c, err := variant(fmt.Sprintf("%s#%d", r.Name, i), limit, period, burst, r.selectKey(i, base))
+		if err != nil {
+			return nil, err
+		}
+		c.fingerprint += fmt.Sprint(ov.Match) // keyed by what it matches, not its position
+		out = append(out, c)
+	}
+	c, err := variant(r.Name, r.Limit, time.Duration(r.Period), r.Burst, base)
+	if err != nil {
+		return nil, err
+	}
+	c.rule.Key = r.selectKey(-1, base)
+	return append(out, c), nil
+}
+
+func (r RuleConfig) keyFunc() KeyFunc {
+	if len(r.Key) == 0 {
+		return GlobalKey()
+	}
+	return KeyBy(r.Key...)
+}
+
+// selectKey applies override i (-1 for the default limit) only to requests for which it is the
+// first matching override
+func (r RuleConfig) selectKey(i int, key KeyFunc) KeyFunc {
+	return func(attrs Attributes) (string, bool) {
+		selected := -1
+		for j, ov := range r.Overrides {
+			if matches(ov.Match, attrs) {
+				selected = j
+				break
+			}
+		}
+		if selected != i {
+			return "", false
+		}
+		return key(attrs)
+	}
+}
+
+func matches(match map[string]string, attrs Attributes) bool {
+	for k, v := range match {
+		if attrs[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// nopStore lets Validate construct limiters without allocating a MemoryStore
+type nopStore struct{}
+
+func (nopStore) Update(context.Context, string, time.Duration, func([]byte) ([]byte, error)) error {
+	return nil
+}
+
+// ------------------- configured limiter ------------------
+
+// ConfiguredLimiter enforces a Config and can be reloaded while serving. All rules keep their
+// state in one Store under a prefix derived from the rule name and its parameters, so a reload
+// keeps the buckets of unchanged rules and starts changed rules from scratch (their old state
+// expires on its own). Evaluation never blocks on a reload.
+type ConfiguredLimiter struct {
+	options []Option
+	engine  atomic.Pointer[configuredEngine]
+}
+
+type configuredEngine struct {
+	cfg      *Config
+	policy   *PolicyEngine
+	limiters map[string]RateLimiter // by prefix, reused across reloads
+}
+
+// NewConfiguredLimiter builds limiters for cfg. options apply to every rule; without WithStore
+// a single MemoryStore (sized by WithMaxKeys) is shared by all rules and kept across reloads.
+func NewConfiguredLimiter(cfg *Config, options ...Option) (*ConfiguredLimiter, error) {
+	o := limiterOptions{now: time.Now}
+	for _, opt := range options {
+		opt(&o)
+	}
+	if o.store == nil {
+		options = append(slices.Clip(options), WithStore(NewMemoryStore(WithStoreMaxKeys(o.maxKeys), WithStoreClock(o.now))))
+	}
+	cl := &ConfiguredLimiter{options: options}
+	if err := cl.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// Reload validates cfg and swaps it in; on error the current config stays active
+func (cl *ConfiguredLimiter) Reload(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var previous map[string]RateLimiter
+	if e := cl.engine.Load(); e != nil {
+		previous = e.limiters
+	}
+	next := &configuredEngine{cfg: cfg, limiters: make(map[string]RateLimiter)}
+	var rules []Rule
+	for _, rc := range cfg.Rules {
+		compiled, err := rc.build()
+		if err != nil {
+			return err
+		}
+		for _, c := range compiled {
+			prefix := rc.Name + ":" + fingerprint(c.fingerprint) + ":"
+			rl, ok := previous[prefix]
+			if !ok {
+				if rl, err = c.newLimiter(append(slices.Clip(cl.options), WithKeyPrefix(prefix))...); err != nil {
+					return err
+				}
+			}
+			next.limiters[prefix] = rl
+			c.rule.Limiter = rl
+			rules = append(rules, c.rule)
+		}
+	}
+	next.policy = NewPolicyEngine(rules...)
+	cl.engine.Store(next)
+	return nil
+}
+
+// Config returns the active config
+func (cl *ConfiguredLimiter) Config() *Config {
+	return cl.engine.Load().cfg
+}
+
+func (cl *ConfiguredLimiter) Decide(ctx context.Context, attrs Attributes, cost int) (PolicyDecision, error) {
+	return cl.engine.Load().policy.Decide(ctx, attrs, cost)
+}
+
+// Allow is Decide for a single unit, failing open on store errors
+func (cl *ConfiguredLimiter) Allow(attrs Attributes) bool {
+	return cl.engine.Load().policy.Allow(attrs)
+}
+
+// WatchFile polls path every interval and reloads it when it changes, until ctx is done.
+// Invalid files are reported to onError (may be nil) and leave the current config active.
+func (cl *ConfiguredLimiter) WatchFile(ctx context.Context, path string, interval time.Duration, onError func(error)) {
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+	var lastMod time.Time
+	var lastSize int64 = -1
+	if fi, err := os.Stat(path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+		cfg, err := LoadConfig(path)
+		if err == nil {
+			err = cl.Reload(cfg)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+}
+
+func fingerprint(s string) string {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
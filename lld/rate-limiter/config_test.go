@@ -0,0 +1,121 @@
+package rate_limiter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRejectsZeroGCRAEmission(t *testing.T) {
+	for _, yaml := range []string{
+		// 10 per 5ns rounds down to an emission interval of 0
+		"rules:\n  - {name: r, algorithm: gcra, limit: 10, period: 5ns}\n",
+		"rules:\n  - {name: r, algorithm: gcra, limit: 1, period: 1s, overrides: [{match: {user: u1}, limit: 10, period: 5ns}]}\n",
+	} {
+		_, err := ParseConfig([]byte(yaml), "yaml")
+		if err == nil || !strings.Contains(err.Error(), "gcra") {
+			t.Errorf("ParseConfig(%q) error = %v, want a gcra error", yaml, err)
+		}
+	}
+}
+
+func TestConfiguredLimiterDoesNotWriteIntoCallerOptions(t *testing.T) {
+	cfg, err := ParseConfig([]byte("rules:\n  - {name: a, algorithm: token_bucket, limit: 1, period: 1s}\n"+
+		"  - {name: b, algorithm: token_bucket, limit: 1, period: 1s, key: [user]}\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentinel := WithKeyPrefix("sentinel:")
+	options := make([]Option, 1, 4)
+	options[0] = WithMaxKeys(100)
+	spare := options[:4]
+	spare[1] = sentinel
+	cl, err := NewConfiguredLimiter(cfg, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o limiterOptions
+	spare[1](&o)
+	if o.keyPrefix != "sentinel:" {
+		t.Fatal("NewConfiguredLimiter appended into the caller's options")
+	}
+	if pd, err := cl.Decide(context.Background(), Attributes{"user": "u1"}, 1); err != nil || !pd.Allowed || len(pd.Rules) != 2 {
+		t.Fatalf("Decide = %+v, %v", pd, err)
+	}
+}
+
+func TestLeakyBucketFractionalRate(t *testing.T) {
+	cfg, err := ParseConfig([]byte("rules:\n  - {name: r, algorithm: leaky_bucket, limit: 100, period: 1m, burst: 2}\n"), "yaml")
+	if err != nil {
+		t.Fatalf("100/min leaky bucket rejected: %v", err)
+	}
+	clock := newFakeClock()
+	cl, err := NewConfiguredLimiter(cfg, WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if pd, _ := cl.Decide(ctx, Attributes{}, 1); !pd.Allowed {
+			t.Fatalf("request %d denied with room in the bucket", i)
+		}
+	}
+	pd, _ := cl.Decide(ctx, Attributes{}, 1)
+	if pd.Allowed || pd.Decision.RetryAfter != 600*time.Millisecond {
+		t.Fatalf("full bucket: %+v, want denied for one 600ms leak", pd.Decision)
+	}
+	clock.set(600 * time.Millisecond)
+	if pd, _ := cl.Decide(ctx, Attributes{}, 1); !pd.Allowed {
+		t.Fatal("denied after one request leaked out")
+	}
+}
+
+func TestReloadKeepsStateOfUnchangedRules(t *testing.T) {
+	parse := func(yaml string) *Config {
+		t.Helper()
+		cfg, err := ParseConfig([]byte(yaml), "yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	const same = "  - {name: same, algorithm: fixed_window, limit: 1, period: 1m, key: [user]}\n"
+	cl, err := NewConfiguredLimiter(parse("rules:\n"+same+
+		"  - {name: changed, algorithm: fixed_window, limit: 1, period: 1m, key: [tenant]}\n"), WithClock(newFakeClock().Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if pd, _ := cl.Decide(ctx, Attributes{"user": "u1"}, 1); !pd.Allowed {
+		t.Fatal("first request denied")
+	}
+	if pd, _ := cl.Decide(ctx, Attributes{"tenant": "t1"}, 1); !pd.Allowed {
+		t.Fatal("first request denied")
+	}
+
+	// reorder the rules and change the second one's limit
+	if err := cl.Reload(parse("rules:\n" +
+		"  - {name: changed, algorithm: fixed_window, limit: 2, period: 1m, key: [tenant]}\n" + same)); err != nil {
+		t.Fatal(err)
+	}
+	if pd, _ := cl.Decide(ctx, Attributes{"user": "u1"}, 1); pd.Allowed {
+		t.Fatal("unchanged rule lost its count across the reload")
+	}
+	for i := 0; i < 2; i++ {
+		if pd, _ := cl.Decide(ctx, Attributes{"tenant": "t1"}, 1); !pd.Allowed {
+			t.Fatalf("changed rule request %d denied, want a fresh bucket with the new limit", i)
+		}
+	}
+
+	// an invalid config leaves everything in place
+	if err := cl.Reload(parse("rules:\n" + same)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Reload(&Config{Rules: []RuleConfig{{Name: "bad", Algorithm: "nope", Limit: 1, Period: Duration(time.Second)}}}); err == nil {
+		t.Fatal("reload with an unknown algorithm succeeded")
+	}
+	if pd, _ := cl.Decide(ctx, Attributes{"user": "u1"}, 1); pd.Allowed || len(cl.Config().Rules) != 1 {
+		t.Fatal("failed reload replaced the active config")
+	}
+}
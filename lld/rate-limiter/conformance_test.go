@@ -0,0 +1,207 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is advanced by the test; limiters read it through WithClock
+type fakeClock struct {
+	start, now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	// aligned to a whole second, the sliding window counter aligns its windows to the epoch
+	t := time.Unix(1_700_000_000, 0)
+	return &fakeClock{start: t, now: t}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// set moves the clock to offset from the start
+func (c *fakeClock) set(offset time.Duration) { c.now = c.start.Add(offset) }
+
+// conformanceStep is one Decide call at an offset from the start of the test
+type conformanceStep struct {
+	at         time.Duration
+	cost       int
+	allowed    bool
+	retryAfter time.Duration // checked on denials when non-zero
+}
+
+// bucketSteps holds for every limiter admitting 4 units per second at a steady 1 per 250ms
+// with a burst of 4: token bucket, leaky bucket and GCRA
+var bucketSteps = []conformanceStep{
+	// burst
+	{at: 0, cost: 1, allowed: true},
+	{at: 0, cost: 1, allowed: true},
+	{at: 0, cost: 1, allowed: true},
+	{at: 0, cost: 1, allowed: true},
+	{at: 0, cost: 1, allowed: false, retryAfter: 250 * time.Millisecond},
+	// one unit comes back exactly 250ms later, not a nanosecond before
+	{at: 250*time.Millisecond - 1, cost: 1, allowed: false},
+	{at: 250 * time.Millisecond, cost: 1, allowed: true},
+	// refilled to the burst after a second, costs above 1
+	{at: 1250 * time.Millisecond, cost: 3, allowed: true},
+	{at: 1250 * time.Millisecond, cost: 2, allowed: false, retryAfter: 250 * time.Millisecond},
+	{at: 1250 * time.Millisecond, cost: 1, allowed: true},
+	{at: 1250 * time.Millisecond, cost: 1, allowed: false},
+}
+
+func TestConformance(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		limit int
+		new   func(now func() time.Time) (RateLimiter, error)
+		steps []conformanceStep
+	}{
+		{
+			name:  "token_bucket",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewTokenBucketRatelimiter(4, time.Second, WithClock(now)), nil
+			},
+			steps: bucketSteps,
+		},
+		{
+			name:  "leaky_bucket",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewLeakyBucketRatelimiter(4, 4, WithClock(now)), nil
+			},
+			steps: bucketSteps,
+		},
+		{
+			name:  "gcra",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewGCRARatelimiter(4, time.Second, WithClock(now))
+			},
+			steps: bucketSteps,
+		},
+		{
+			name:  "fixed_window",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewFixedWindowRatelimiter(WithMaxRequests(4), WithWindowSize(time.Second), WithClock(now)), nil
+			},
+			steps: []conformanceStep{
+				{at: 0, cost: 1, allowed: true},
+				{at: 0, cost: 3, allowed: true},
+				{at: 500 * time.Millisecond, cost: 1, allowed: false, retryAfter: 500 * time.Millisecond},
+				// the window opened by the first request still applies at its very end
+				{at: time.Second, cost: 1, allowed: false},
+				{at: time.Second + 1, cost: 3, allowed: true},
+				{at: time.Second + 1, cost: 2, allowed: false, retryAfter: time.Second},
+				{at: time.Second + 1, cost: 1, allowed: true},
+			},
+		},
+		{
+			name:  "sliding_window",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewSlidingWindowRatelimiter(4, time.Second, WithClock(now)), nil
+			},
+			steps: []conformanceStep{
+				{at: 0, cost: 2, allowed: true},
+				{at: 500 * time.Millisecond, cost: 2, allowed: true},
+				{at: 500 * time.Millisecond, cost: 1, allowed: false, retryAfter: 500 * time.Millisecond},
+				// requests stay in the window for a full windowSize
+				{at: time.Second, cost: 1, allowed: false},
+				{at: time.Second + 1, cost: 3, allowed: false},
+				{at: time.Second + 1, cost: 2, allowed: true},
+				{at: 1500*time.Millisecond + 1, cost: 3, allowed: false},
+				{at: 1500*time.Millisecond + 1, cost: 2, allowed: true},
+			},
+		},
+		{
+			name:  "sliding_window_counter",
+			limit: 4,
+			new: func(now func() time.Time) (RateLimiter, error) {
+				return NewSlidingWindowCounterRatelimiter(4, time.Second, WithClock(now))
+			},
+			steps: []conformanceStep{
+				{at: 0, cost: 1, allowed: true},
+				{at: 0, cost: 3, allowed: true},
+				{at: 0, cost: 1, allowed: false, retryAfter: 1250 * time.Millisecond},
+				// the previous window still weighs fully at the start of the next one
+				{at: time.Second, cost: 1, allowed: false},
+				// 4*0.75 = 3 counted
+				{at: 1250 * time.Millisecond, cost: 1, allowed: true},
+				{at: 1250 * time.Millisecond, cost: 1, allowed: false},
+				// 4*0.25 + 1 = 2 counted
+				{at: 1750 * time.Millisecond, cost: 3, allowed: false},
+				{at: 1750 * time.Millisecond, cost: 2, allowed: true},
+				// both windows have slid out
+				{at: 3 * time.Second, cost: 4, allowed: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl, err := tc.new(clock.Now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			for i, s := range tc.steps {
+				clock.set(s.at)
+				d, err := rl.Decide(ctx, "k", s.cost)
+				if err != nil {
+					t.Fatalf("step %d: Decide(%d) at %v: %v", i, s.cost, s.at, err)
+				}
+				if d.Allowed != s.allowed {
+					t.Fatalf("step %d: Decide(%d) at %v: allowed = %v, want %v", i, s.cost, s.at, d.Allowed, s.allowed)
+				}
+				if d.Limit != tc.limit {
+					t.Fatalf("step %d: Limit = %d, want %d", i, d.Limit, tc.limit)
+				}
+				if d.Remaining < 0 || d.Remaining > tc.limit {
+					t.Fatalf("step %d: Remaining = %d out of [0, %d]", i, d.Remaining, tc.limit)
+				}
+				if !s.allowed && s.retryAfter != 0 && d.RetryAfter != s.retryAfter {
+					t.Fatalf("step %d: RetryAfter = %v, want %v", i, d.RetryAfter, s.retryAfter)
+				}
+			}
+
+			// costs the limiter can never serve, independent of its state
+			if d, err := rl.Decide(ctx, "other", tc.limit+1); !errors.Is(err, ErrCostExceedsLimit) || d.Allowed {
+				t.Fatalf("Decide(limit+1) = %+v, %v; want denied with ErrCostExceedsLimit", d, err)
+			}
+			if _, err := rl.Decide(ctx, "other", -1); !errors.Is(err, ErrInvalidCost) {
+				t.Fatalf("Decide(-1) error = %v, want ErrInvalidCost", err)
+			}
+		})
+	}
+}
+
+func TestConstructorsRejectDegenerateRates(t *testing.T) {
+	if _, err := NewGCRARatelimiter(0, time.Second); err == nil {
+		t.Error("gcra with limit 0 should fail")
+	}
+	// period/limit rounds down to a zero emission interval
+	if _, err := NewGCRARatelimiter(10, 5*time.Nanosecond); err == nil {
+		t.Error("gcra with a zero emission interval should fail")
+	}
+	if _, err := NewSlidingWindowCounterRatelimiter(4, 0); err == nil {
+		t.Error("sliding window counter with a zero window should fail")
+	}
+}
+
+func TestSlidingWindowCounterAlignsToEpoch(t *testing.T) {
+	// 700s is a multiple of 7s since the Unix epoch but not since the zero time
+	now := time.Unix(703, 0)
+	rl, err := NewSlidingWindowCounterRatelimiter(4, 7*time.Second, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := rl.Decide(context.Background(), "k", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(714, 0); !d.ResetAt.Equal(want) {
+		t.Fatalf("ResetAt = %v, want two windows after the window starting at 700s (%v)", d.ResetAt.Unix(), want.Unix())
+	}
+}
@@ -0,0 +1,43 @@
+package rate_limiter
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidCost = errors.New("rate limiter: cost must not be negative")
+	// ErrCostExceedsLimit is returned (with a denied Decision) when cost is larger than the limit
+	// itself, so the request could never be allowed no matter how long the caller waits
+	ErrCostExceedsLimit = errors.New("rate limiter: cost exceeds limit")
+)
+
+// Decision is the outcome of RateLimiter.Decide
+type Decision struct {
+	Allowed    bool
+	Limit      int           // quota size: max requests per window, or bucket capacity
+	Remaining  int           // units left after this decision
+	ResetAt    time.Time     // when the quota is fully restored
+	RetryAfter time.Duration // when denied, how long until cost units are available; 0 when allowed
+}
+
+// WriteHeaders sets the IETF draft RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset headers
+// and, for denied decisions, Retry-After. Durations are rounded up to whole seconds.
+func (d Decision) WriteHeaders(h http.Header, now time.Time) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(d.Remaining, 0)))
+	h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(d.ResetAt.Sub(now)), 10))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(ceilSeconds(d.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
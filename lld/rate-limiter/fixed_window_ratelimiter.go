@@ -1,66 +1,65 @@
 package rate_limiter
 
 import (
-	"sync"
+	"context"
 	"time"
 )
 
 type fixedWindowRatelimiter struct {
+	base
 	maxRequests int
 	windowSize  time.Duration
-	// make map sync
-	requests sync.Map
-	mu       sync.Mutex
 }
 
-type requestData struct {
-	requestCount    int
-	windowStartTime time.Time
-}
+// fixed window state: [requestCount, windowStartTime (unix nanos)]
 
-func NewFixedWindowRatelimiter(options ...func(*fixedWindowRatelimiter)) *fixedWindowRatelimiter {
-	ratelimiter := &fixedWindowRatelimiter{
-		maxRequests: 0,
-		windowSize:  0,
-		requests:    sync.Map{},
-	}
-	for _, o := range options {
-		o(ratelimiter)
+func NewFixedWindowRatelimiter(options ...Option) *fixedWindowRatelimiter {
+	b, o := newBase("fixed_window:", options)
+	return &fixedWindowRatelimiter{
+		base:        b,
+		maxRequests: o.maxRequests,
+		windowSize:  o.windowSize,
 	}
-	return ratelimiter
 }
 
-func WithMaxRequests(maxRequests int) func(*fixedWindowRatelimiter) {
-	return func(ratelimiter *fixedWindowRatelimiter) {
-		ratelimiter.maxRequests = maxRequests
-	}
+func (ratelimiter *fixedWindowRatelimiter) AllowRequest(key string) bool {
+	return allowRequest(ratelimiter, key)
 }
-func WithWindowSize(windowSize time.Duration) func(*fixedWindowRatelimiter) {
-	return func(ratelimiter *fixedWindowRatelimiter) {
-		ratelimiter.windowSize = windowSize
-	}
+
+func (ratelimiter *fixedWindowRatelimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	return ratelimiter.decide(ctx, key, cost, ratelimiter.windowSize, ratelimiter.step)
 }
 
-func (ratelimiter *fixedWindowRatelimiter) AllowRequest(key string) bool {
-	currentTime := time.Now()
-	reqDataAny, exists := ratelimiter.requests.Load(key)
-	reqData, _ := reqDataAny.(requestData)
-	if !exists {
-		reqData.requestCount = 1
-		reqData.windowStartTime = currentTime
-		ratelimiter.requests.Store(key, reqData)
-		return true
+func (ratelimiter *fixedWindowRatelimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	return ratelimiter.dryRun(ctx, key, cost, ratelimiter.step)
+}
+
+func (ratelimiter *fixedWindowRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	requestCount, windowStartTime := 0, currentTime
+	if w, ok := decodeState(state, 2); ok {
+		requestCount, windowStartTime = int(w[0]), time.Unix(0, int64(w[1]))
 	}
-	if currentTime.Sub(reqData.windowStartTime) > ratelimiter.windowSize {
-		reqData.requestCount = 1
-		reqData.windowStartTime = currentTime
-		ratelimiter.requests.Store(key, reqData)
-		return true
+	if currentTime.Sub(windowStartTime) > ratelimiter.windowSize {
+		requestCount, windowStartTime = 0, currentTime
 	}
-	if reqData.requestCount < ratelimiter.maxRequests {
-		reqData.requestCount++
-		ratelimiter.requests.Store(key, reqData)
-		return true
+	d := Decision{Limit: ratelimiter.maxRequests, ResetAt: windowStartTime.Add(ratelimiter.windowSize)}
+	d.Allowed = requestCount+cost <= ratelimiter.maxRequests
+	if d.Allowed {
+		requestCount += cost
+	} else {
+		d.RetryAfter = d.ResetAt.Sub(currentTime)
 	}
-	return false
+	d.Remaining = ratelimiter.maxRequests - requestCount
+	return encodeState(uint64(requestCount), uint64(windowStartTime.UnixNano())), d
+}
+
+// refund gives back n requests counted in the current window
+func (ratelimiter *fixedWindowRatelimiter) refund(ctx context.Context, key string, n int) error {
+	return ratelimiter.update(ctx, key, ratelimiter.windowSize, func(state []byte, currentTime time.Time) []byte {
+		w, ok := decodeState(state, 2)
+		if !ok {
+			return state
+		}
+		return encodeState(uint64(max(int(w[0])-n, 0)), w[1])
+	})
 }
@@ -0,0 +1,86 @@
+package rate_limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// gcraRatelimiter implements the generic cell rate algorithm: limit requests per period, spaced
+// by the emission interval T = period/limit, with bursts of up to limit requests.
+// The only state is the theoretical arrival time (TAT) of the next request. A request costing n
+// is allowed if, after pushing TAT forward by n*T, TAT is at most one period ahead of now.
+type gcraRatelimiter struct {
+	base
+	limit  int
+	period time.Duration
+}
+
+// gcra state: [TAT (unix nanos)]
+
+// NewGCRARatelimiter fails unless limit is positive and the emission interval period/limit is at
+// least a nanosecond
+func NewGCRARatelimiter(limit int, period time.Duration, options ...Option) (*gcraRatelimiter, error) {
+	if limit <= 0 || period/time.Duration(limit) <= 0 {
+		return nil, fmt.Errorf("rate limiter: gcra needs a positive limit and period/limit of at least 1ns, got %d per %v", limit, period)
+	}
+	b, _ := newBase("gcra:", options)
+	return &gcraRatelimiter{
+		base:   b,
+		limit:  limit,
+		period: period,
+	}, nil
+}
+
+func (rl *gcraRatelimiter) AllowRequest(key string) bool {
+	return allowRequest(rl, key)
+}
+
+func (rl *gcraRatelimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	// TAT is never more than one period ahead, after that the state is irrelevant
+	return rl.decide(ctx, key, cost, rl.period, rl.step)
+}
+
+func (rl *gcraRatelimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.dryRun(ctx, key, cost, rl.step)
+}
+
+func (rl *gcraRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	emission := rl.period / time.Duration(rl.limit)
+	tat := currentTime
+	if w, ok := decodeState(state, 1); ok {
+		if stored := time.Unix(0, int64(w[0])); stored.After(currentTime) {
+			tat = stored
+		}
+	}
+
+	newTAT := tat.Add(time.Duration(cost) * emission)
+	allowAt := newTAT.Add(-rl.period)
+	d := Decision{Limit: rl.limit}
+	d.Allowed = !currentTime.Before(allowAt)
+	if d.Allowed {
+		tat = newTAT
+	} else {
+		d.RetryAfter = allowAt.Sub(currentTime)
+	}
+	// remaining = how many more emission intervals fit before TAT is a full period ahead
+	d.Remaining = max(int(currentTime.Add(rl.period).Sub(tat)/emission), 0)
+	d.ResetAt = tat
+	return encodeState(uint64(tat.UnixNano())), d
+}
+
+// refund moves TAT back by n emission intervals, never before now
+func (rl *gcraRatelimiter) refund(ctx context.Context, key string, n int) error {
+	return rl.update(ctx, key, rl.period, func(state []byte, currentTime time.Time) []byte {
+		w, ok := decodeState(state, 1)
+		if !ok {
+			return state
+		}
+		emission := rl.period / time.Duration(rl.limit)
+		tat := time.Unix(0, int64(w[0])).Add(-time.Duration(n) * emission)
+		if tat.Before(currentTime) {
+			tat = currentTime
+		}
+		return encodeState(uint64(tat.UnixNano()))
+	})
+}
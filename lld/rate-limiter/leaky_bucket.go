@@ -1,113 +1,139 @@
 package rate_limiter
 
 import (
-	"sync"
+	"context"
+	"math"
 	"time"
 )
 
-// leakyBucket represents a leaky bucket rate limiter for a specific customer
-type leakyBucket struct {
-	capacity   int           // Maximum capacity of the bucket
-	rps        int           // Requests per second allowed to pass
-	queue      chan struct{} // Channel acting as a queue for incoming requests
-	mu         sync.Mutex    // Mutex for safe concurrent access
-	stopSignal chan struct{} // Channel to signal when to stop leaking
+// LeakyBucketRatelimiter manages rate limiting for multiple customers, each with their own leaky bucket.
+// A bucket holds up to bucketCap queued requests and leaks leakRate per second. The leak is computed
+// lazily from the last update time, so buckets cost no goroutines and can live in a shared Store.
+type LeakyBucketRatelimiter struct {
+	base
+	bucketCap int     // Bucket capacity for each customer
+	leakRate  float64 // Requests per second leaked from each customer's bucket
+}
+
+// leaky bucket state: [level (float64 bits), lastLeakTimestamp (unix nanos)]
+
+// NewLeakyBucketRatelimiter initializes a new LeakyBucketRatelimiter
+func NewLeakyBucketRatelimiter(bucketCap, bucketRPS int, options ...Option) *LeakyBucketRatelimiter {
+	return newLeakyBucketRatelimiter(bucketCap, float64(bucketRPS), options...)
 }
 
-// newLeakyBucket initializes a new leakyBucket with given capacity and rps
-func newLeakyBucket(capacity, rps int) *leakyBucket {
-	bucket := &leakyBucket{
-		capacity:   capacity,
-		rps:        rps,
-		queue:      make(chan struct{}, capacity),
-		stopSignal: make(chan struct{}),
+// newLeakyBucketRatelimiter takes a fractional leak rate, e.g. 100 requests a minute
+func newLeakyBucketRatelimiter(bucketCap int, leakRate float64, options ...Option) *LeakyBucketRatelimiter {
+	b, _ := newBase("leaky_bucket:", options)
+	return &LeakyBucketRatelimiter{
+		base:      b,
+		bucketCap: bucketCap,
+		leakRate:  leakRate,
 	}
-	go bucket.startLeaking()
-	return bucket
 }
 
-// allowRequest checks if a request is allowed based on the rate limit
-func (b *leakyBucket) allowRequest() bool {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// AllowRequest checks if a request is allowed for the given customer ID.
+// Fails open if the store is unavailable.
+func (rl *LeakyBucketRatelimiter) AllowRequest(customerID string) bool {
+	return allowRequest(rl, customerID)
+}
 
-	select {
-	case b.queue <- struct{}{}: // Add a request to the queue if capacity allows
-		return true
-	default:
-		return false // Bucket is full, request is not allowed
-	}
+func (rl *LeakyBucketRatelimiter) Decide(ctx context.Context, customerID string, cost int) (Decision, error) {
+	return rl.decide(ctx, customerID, cost, rl.drainTime(), rl.step)
 }
 
-// startLeaking continuously drains the bucket at the defined rate (rps)
-func (b *leakyBucket) startLeaking() {
-	ticker := time.NewTicker(time.Second / time.Duration(b.rps))
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			b.mu.Lock()
-			select {
-			case <-b.queue: // Remove a request from the queue
-			default:
-				// No requests in the queue to process
-			}
-			b.mu.Unlock()
-		case <-b.stopSignal:
-			return // stop leaking if we receive a stop signal
-		}
-	}
+func (rl *LeakyBucketRatelimiter) peek(ctx context.Context, customerID string, cost int) (Decision, error) {
+	return rl.dryRun(ctx, customerID, cost, rl.step)
 }
 
-// stop stops the leaky bucket from leaking
-func (b *leakyBucket) stop() {
-	close(b.stopSignal)
+func (rl *LeakyBucketRatelimiter) AllowN(customerID string, n int) bool {
+	return allowN(rl, customerID, n)
 }
 
-// LeakyBucketRatelimiter manages rate limiting for multiple customers, each with their own leakyBucket
-type LeakyBucketRatelimiter struct {
-	buckets   map[string]*leakyBucket // Map of customer ID to their respective leakyBucket
-	bucketCap int                     // Bucket capacity for each customer
-	bucketRPS int                     // Requests per second for each customer's bucket
-	mu        sync.Mutex              // Mutex for safe access to the buckets map
+func (rl *LeakyBucketRatelimiter) Wait(ctx context.Context, customerID string, n int) error {
+	return wait(ctx, rl, customerID, n, rl.now)
 }
 
-// NewRateLimiter initializes a new LeakyBucketRatelimiter
-func NewLeakyBucketRatelimiter(bucketCap, bucketRPS int) *LeakyBucketRatelimiter {
-	return &LeakyBucketRatelimiter{
-		buckets:   make(map[string]*leakyBucket),
-		bucketCap: bucketCap,
-		bucketRPS: bucketRPS,
+// Reserve queues n requests even if the bucket is full, letting it overflow by at most bucketCap.
+// The caller acts once the overflow has leaked out.
+func (rl *LeakyBucketRatelimiter) Reserve(ctx context.Context, customerID string, n int) (*Reservation, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+	if n > rl.bucketCap {
+		return &Reservation{}, ErrCostExceedsLimit
+	}
+	r := &Reservation{tokens: n}
+	err := rl.update(ctx, customerID, rl.drainTime(), func(state []byte, currentTime time.Time) []byte {
+		level := rl.leak(state, currentTime)
+		r.timeToAct = currentTime
+		r.ok = level+float64(n) <= 2*float64(rl.bucketCap)
+		if r.ok {
+			level += float64(n)
+			if over := level - float64(rl.bucketCap); over > 0 {
+				r.timeToAct = currentTime.Add(rl.timeToLeak(over))
+			}
+		}
+		return encodeState(math.Float64bits(level), uint64(currentTime.UnixNano()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.refund = func(ctx context.Context) error {
+		return rl.refund(ctx, customerID, n)
 	}
+	return r, nil
 }
 
-// getOrCreateBucket retrieves or creates a leakyBucket for a given customer ID
-func (rl *LeakyBucketRatelimiter) getOrCreateBucket(customerID string) *leakyBucket {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// refund removes n queued requests from the bucket
+func (rl *LeakyBucketRatelimiter) refund(ctx context.Context, customerID string, n int) error {
+	return rl.update(ctx, customerID, rl.drainTime(), func(state []byte, currentTime time.Time) []byte {
+		level := math.Max(rl.leak(state, currentTime)-float64(n), 0)
+		return encodeState(math.Float64bits(level), uint64(currentTime.UnixNano()))
+	})
+}
 
-	// Check if a bucket exists for the customer; if not, create one
-	bucket, exists := rl.buckets[customerID]
-	if !exists {
-		bucket = newLeakyBucket(rl.bucketCap, rl.bucketRPS)
-		rl.buckets[customerID] = bucket
+func (rl *LeakyBucketRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	level := rl.leak(state, currentTime)
+	d := Decision{Limit: rl.bucketCap}
+	d.Allowed = level+float64(cost) <= float64(rl.bucketCap)
+	if d.Allowed {
+		level += float64(cost)
+	} else {
+		d.RetryAfter = rl.timeToLeak(level + float64(cost) - float64(rl.bucketCap))
 	}
-	return bucket
+	d.Remaining = max(int(float64(rl.bucketCap)-level), 0)
+	d.ResetAt = currentTime.Add(rl.timeToLeak(level))
+	return encodeState(math.Float64bits(level), uint64(currentTime.UnixNano())), d
 }
 
-// AllowRequest checks if a request is allowed for the given customer ID
-func (rl *LeakyBucketRatelimiter) AllowRequest(customerID string) bool {
-	bucket := rl.getOrCreateBucket(customerID)
-	return bucket.allowRequest()
+// StopAll is kept for existing callers; buckets no longer run goroutines so there is nothing to stop
+func (rl *LeakyBucketRatelimiter) StopAll() {}
+
+// leak returns the bucket level at currentTime
+func (rl *LeakyBucketRatelimiter) leak(state []byte, currentTime time.Time) float64 {
+	w, ok := decodeState(state, 2)
+	if !ok {
+		return 0
+	}
+	level := math.Float64frombits(w[0])
+	elapsed := currentTime.Sub(time.Unix(0, int64(w[1])))
+	if elapsed > 0 {
+		level -= elapsed.Seconds() * rl.leakRate
+	}
+	return math.Max(level, 0)
 }
 
-// StopAll stops all leaky buckets managed by the rate limiter
-func (rl *LeakyBucketRatelimiter) StopAll() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// drainTime is how long a bucket overflowing by the maximum reservation takes to empty;
+// after that its state can be dropped
+func (rl *LeakyBucketRatelimiter) drainTime() time.Duration {
+	return rl.timeToLeak(2 * float64(rl.bucketCap))
+}
 
-	for _, bucket := range rl.buckets {
-		bucket.stop()
+// timeToLeak is how long it takes n queued requests to leak out
+func (rl *LeakyBucketRatelimiter) timeToLeak(n float64) time.Duration {
+	if rl.leakRate <= 0 {
+		return 0
 	}
+	return time.Duration(math.Ceil(n / rl.leakRate * float64(time.Second)))
 }
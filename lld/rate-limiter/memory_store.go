@@ -0,0 +1,240 @@
+package rate_limiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is the in-process Store, the default for every limiter.
+// Memory stays bounded with millions of keys:
+//   - every write carries a TTL (the time after which the limiter state is back to "fresh"),
+//     keys written without one fall back to the idle TTL (WithStoreIdleTTL)
+//   - each write sweeps a few expired keys from the least recently used end, and an optional
+//     janitor (WithStoreJanitor) sweeps everything periodically
+//   - WithStoreMaxKeys caps the tracked keys, evicting the least recently used one. An evicted key
+//     starts over with a fresh limit, so size the cap above the number of concurrently active keys.
+type MemoryStore struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List // front = most recently written
+	seq      uint64
+	maxKeys  int           // 0 = unlimited
+	idleTTL  time.Duration // applied to writes without a ttl, 0 = keep forever
+	now      func() time.Time
+	janitor  time.Duration // sweep interval, 0 = no janitor
+	closed   chan struct{}
+	stats    MemoryStoreStats
+	stopOnce sync.Once
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+	version   uint64    // store-wide sequence of the last write, used for WATCH by the RESP server
+}
+
+type MemoryStoreStats struct {
+	Keys        int
+	Evictions   uint64 // removed by the max keys cap
+	Expirations uint64 // removed after their TTL
+}
+
+// sweepPerWrite is how many least recently used keys each write checks for expiry
+const sweepPerWrite = 4
+
+type MemoryStoreOption func(*MemoryStore)
+
+func WithStoreMaxKeys(maxKeys int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.maxKeys = maxKeys
+	}
+}
+
+func WithStoreIdleTTL(idleTTL time.Duration) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.idleTTL = idleTTL
+	}
+}
+
+// WithStoreClock replaces time.Now for TTLs
+func WithStoreClock(now func() time.Time) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.now = now
+	}
+}
+
+// WithStoreJanitor starts a goroutine sweeping all expired keys every interval, until Close
+func WithStoreJanitor(interval time.Duration) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.janitor = interval
+	}
+}
+
+func NewMemoryStore(options ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+		closed:  make(chan struct{}),
+	}
+	for _, o := range options {
+		o(s)
+	}
+	// started after the options so it sees the final clock
+	if s.janitor > 0 {
+		go s.sweepLoop(s.janitor)
+	}
+	return s
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current []byte
+	if e := s.live(key); e != nil {
+		current = e.value
+	}
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	s.set(key, next, ttl)
+	return nil
+}
+
+// Get returns the value of key and its version (0 if missing)
+func (s *MemoryStore) Get(key string) ([]byte, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.live(key); e != nil {
+		return e.value, e.version
+	}
+	return nil, 0
+}
+
+// Set stores value for ttl (0 means the idle TTL)
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, ttl)
+}
+
+// Delete removes key and reports whether it existed
+func (s *MemoryStore) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.live(key) == nil {
+		return false
+	}
+	s.remove(s.entries[key])
+	return true
+}
+
+// Len returns the number of tracked keys, including expired ones not yet reclaimed
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *MemoryStore) Stats() MemoryStoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.Keys = len(s.entries)
+	return st
+}
+
+// Close stops the janitor, if any
+func (s *MemoryStore) Close() {
+	s.stopOnce.Do(func() { close(s.closed) })
+}
+
+// internals, caller must hold s.mu
+
+// live returns the entry for key, dropping it if expired
+func (s *MemoryStore) live(key string) *memoryEntry {
+	el, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*memoryEntry)
+	if s.expired(e, s.now()) {
+		s.remove(el)
+		s.stats.Expirations++
+		return nil
+	}
+	return e
+}
+
+// set writes key with a fresh version, unique even across expiry and re-creation
+func (s *MemoryStore) set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = s.idleTTL
+	}
+	el, ok := s.entries[key]
+	if ok {
+		s.lru.MoveToFront(el)
+	} else {
+		el = s.lru.PushFront(&memoryEntry{key: key})
+		s.entries[key] = el
+	}
+	e := el.Value.(*memoryEntry)
+	s.seq++
+	e.value = value
+	e.version = s.seq
+	e.expiresAt = time.Time{}
+	if ttl > 0 {
+		e.expiresAt = s.now().Add(ttl)
+	}
+
+	s.sweep(sweepPerWrite)
+	for s.maxKeys > 0 && len(s.entries) > s.maxKeys {
+		s.remove(s.lru.Back())
+		s.stats.Evictions++
+	}
+}
+
+// sweep checks up to n keys from the least recently used end and drops expired ones (n < 0 = all)
+func (s *MemoryStore) sweep(n int) {
+	now := s.now()
+	for el := s.lru.Back(); el != nil && n != 0; n-- {
+		prev := el.Prev()
+		if s.expired(el.Value.(*memoryEntry), now) {
+			s.remove(el)
+			s.stats.Expirations++
+		}
+		el = prev
+	}
+}
+
+func (s *MemoryStore) expired(e *memoryEntry, now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	delete(s.entries, el.Value.(*memoryEntry).key)
+	s.lru.Remove(el)
+}
+
+func (s *MemoryStore) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			s.sweep(-1)
+			s.mu.Unlock()
+		case <-s.closed:
+			return
+		}
+	}
+}
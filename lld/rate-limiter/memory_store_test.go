@@ -0,0 +1,29 @@
+package rate_limiter
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreJanitorUsesStoreClock(t *testing.T) {
+	var offset atomic.Int64
+	start := time.Now()
+	clock := func() time.Time { return start.Add(time.Duration(offset.Load())) }
+	// the janitor option comes first and must still see the clock set after it
+	s := NewMemoryStore(WithStoreJanitor(time.Millisecond), WithStoreClock(clock))
+	defer s.Close()
+
+	s.Set("k", []byte("v"), time.Minute)
+	offset.Store(int64(2 * time.Minute))
+	deadline := time.Now().Add(time.Second)
+	for s.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not sweep the expired key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if st := s.Stats(); st.Expirations != 1 {
+		t.Fatalf("Expirations = %d, want 1", st.Expirations)
+	}
+}
@@ -0,0 +1,302 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ------------------- key extraction ------------------
+
+// RequestKeyFunc derives the limiter key of an HTTP request; ok=false lets the request through unlimited
+type RequestKeyFunc func(r *http.Request) (key string, ok bool)
+
+// ClientIP keys requests by client address. When the direct peer is one of trustedProxies
+// (IPs or CIDRs, e.g. "10.0.0.0/8"), X-Forwarded-For is walked from the right and the first
+// address not belonging to a trusted proxy is used; otherwise the header is ignored, so clients
+// cannot pick their own key by spoofing it.
+func ClientIP(trustedProxies ...string) (RequestKeyFunc, error) {
+	var trusted []netip.Prefix
+	for _, p := range trustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(p)
+			if aerr != nil {
+				return nil, fmt.Errorf("rate limiter: bad trusted proxy %q: %w", p, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		trusted = append(trusted, prefix.Masked())
+	}
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		client, err := netip.ParseAddr(host)
+		if err != nil {
+			return "", false
+		}
+		client = client.Unmap()
+		if !isTrusted(client) {
+			return client.String(), true
+		}
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break // garbage from an untrusted hop, fall back to the last trusted address
+			}
+			client = addr.Unmap()
+			if !isTrusted(client) {
+				break
+			}
+		}
+		return client.String(), true
+	}, nil
+}
+
+// HeaderKey keys requests by the value of a header, skipping requests without it
+func HeaderKey(name string) RequestKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		v := r.Header.Get(name)
+		return v, v != ""
+	}
+}
+
+// APIKey keys requests by their API key, taken from "Authorization: Bearer <key>" or X-API-Key
+func APIKey() RequestKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			return strings.TrimSpace(auth[7:]), true
+		}
+		v := r.Header.Get("X-API-Key")
+		return v, v != ""
+	}
+}
+
+// RouteKey keys requests by method and path, e.g. "GET /search"
+func RouteKey() RequestKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return r.Method + " " + r.URL.Path, true
+	}
+}
+
+// CombineKeys joins several keys, e.g. CombineKeys(APIKey(), RouteKey()) for per-key-per-route
+// limits. The request is unlimited if any part is missing.
+func CombineKeys(keys ...RequestKeyFunc) RequestKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			v, ok := key(r)
+			if !ok {
+				return "", false
+			}
+			parts[i] = v
+		}
+		return strings.Join(parts, "|"), true
+	}
+}
+
+// ------------------- HTTP middleware ------------------
+
+// MiddlewareOption configures Middleware and PolicyMiddleware
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	key     RequestKeyFunc
+	cost    func(r *http.Request) int
+	denied  func(w http.ResponseWriter, r *http.Request, d Decision)
+	onError func(w http.ResponseWriter, r *http.Request, err error) bool
+	now     func() time.Time
+}
+
+// WithRequestKey sets how requests are keyed, defaults to ClientIP with no trusted proxies
+func WithRequestKey(key RequestKeyFunc) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.key = key
+	}
+}
+
+// WithRequestCost charges requests a variable number of units, defaults to 1. Negative costs
+// are answered with 500.
+func WithRequestCost(cost func(r *http.Request) int) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.cost = cost
+	}
+}
+
+// WithDeniedBody replaces the default plain-text 429 body
+func WithDeniedBody(contentType string, body []byte) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.denied = func(w http.ResponseWriter, _ *http.Request, _ Decision) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(body)
+		}
+	}
+}
+
+// WithDeniedHandler takes over the response for denied requests. Rate limit headers are already set.
+func WithDeniedHandler(denied func(w http.ResponseWriter, r *http.Request, d Decision)) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.denied = denied
+	}
+}
+
+// WithErrorHandler handles limiter errors such as an unreachable store. It returns whether the
+// request should still be served. The default fails open.
+func WithErrorHandler(onError func(w http.ResponseWriter, r *http.Request, err error) bool) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.onError = onError
+	}
+}
+
+// WithMiddlewareClock replaces time.Now when computing header values
+func WithMiddlewareClock(now func() time.Time) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.now = now
+	}
+}
+
+func newMiddlewareOptions(options []MiddlewareOption) middlewareOptions {
+	key, _ := ClientIP()
+	o := middlewareOptions{
+		key:  key,
+		cost: func(*http.Request) int { return 1 },
+		denied: func(w http.ResponseWriter, _ *http.Request, _ Decision) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		},
+		onError: func(http.ResponseWriter, *http.Request, error) bool { return true },
+		now:     time.Now,
+	}
+	for _, opt := range options {
+		opt(&o)
+	}
+	return o
+}
+
+// Middleware limits requests with rl. Every limited response carries RateLimit-* headers;
+// denied requests get 429 with Retry-After and never reach next. Requests the key function
+// skips, or that cost more than the whole limit, are handled as unlimited and denied respectively.
+// A negative cost is a bug in the cost function and answered with 500.
+func Middleware(rl RateLimiter, options ...MiddlewareOption) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions(options)
+	return o.wrap(func(r *http.Request, cost int) (Decision, bool, error) {
+		key, ok := o.key(r)
+		if !ok {
+			return Decision{}, false, nil
+		}
+		d, err := rl.Decide(r.Context(), key, cost)
+		if errors.Is(err, ErrCostExceedsLimit) {
+			err = nil
+		}
+		return d, true, err
+	})
+}
+
+// Policy is a multi-rule limiter such as PolicyEngine or ConfiguredLimiter
+type Policy interface {
+	Decide(ctx context.Context, attrs Attributes, cost int) (PolicyDecision, error)
+}
+
+// PolicyMiddleware limits requests with every matching rule of p; attrs describes a request,
+// e.g. {"user": ..., "endpoint": ...}. WithRequestKey is ignored. Headers report the most
+// restrictive rule.
+func PolicyMiddleware(p Policy, attrs func(r *http.Request) Attributes, options ...MiddlewareOption) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions(options)
+	return o.wrap(func(r *http.Request, cost int) (Decision, bool, error) {
+		pd, err := p.Decide(r.Context(), attrs(r), cost)
+		if errors.Is(err, ErrRefund) {
+			// the request is denied either way
+			err = nil
+		}
+		return pd.Decision, len(pd.Rules) > 0, err
+	})
+}
+
+func (o middlewareOptions) wrap(decide func(r *http.Request, cost int) (d Decision, limited bool, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cost := o.cost(r)
+			if cost < 0 {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			d, limited, err := decide(r, cost)
+			if err != nil {
+				if o.onError(w, r, err) {
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+			if !limited {
+				next.ServeHTTP(w, r)
+				return
+			}
+			d.WriteHeaders(w.Header(), o.now())
+			if !d.Allowed {
+				o.denied(w, r, d)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ------------------- unary interceptor ------------------
+
+// ErrRateLimited is matched (errors.Is) by the *LimitError an interceptor returns for denied calls
+var ErrRateLimited = errors.New("rate limiter: rate limited")
+
+// LimitError carries the Decision of a denied call so servers can map it to their protocol,
+// e.g. RESOURCE_EXHAUSTED with a retry delay.
+type LimitError struct {
+	Decision Decision
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.Decision.RetryAfter)
+}
+
+func (e *LimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// UnaryHandler and UnaryInterceptor have the shape of RPC server interceptors (e.g. gRPC's
+// UnaryServerInterceptor with the method name taken from its info argument), so adapting
+// them is a one-line closure.
+type (
+	UnaryHandler     func(ctx context.Context, req any) (any, error)
+	UnaryInterceptor func(ctx context.Context, method string, req any, handler UnaryHandler) (any, error)
+)
+
+// NewUnaryInterceptor limits calls with rl, keyed by key (ok=false skips limiting). Denied calls
+// return a *LimitError without invoking the handler; limiter errors fail open.
+func NewUnaryInterceptor(rl RateLimiter, key func(ctx context.Context, method string, req any) (string, bool)) UnaryInterceptor {
+	return func(ctx context.Context, method string, req any, handler UnaryHandler) (any, error) {
+		k, ok := key(ctx, method, req)
+		if !ok {
+			return handler(ctx, req)
+		}
+		d, err := rl.Decide(ctx, k, 1)
+		if err != nil && !errors.Is(err, ErrCostExceedsLimit) {
+			return handler(ctx, req)
+		}
+		if !d.Allowed {
+			return nil, &LimitError{Decision: d}
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,158 @@
+package rate_limiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestMiddlewareAllowsThenDenies(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(2), WithWindowSize(time.Minute), WithClock(clock.Now))
+	h := Middleware(rl, WithMiddlewareClock(clock.Now))(okHandler)
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := serve(h, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+			t.Fatalf("request %d: %d %q", i, rec.Code, rec.Body)
+		}
+		hdr := rec.Header()
+		if hdr.Get("RateLimit-Limit") != "2" || hdr.Get("RateLimit-Remaining") != remaining || hdr.Get("RateLimit-Reset") != "60" {
+			t.Fatalf("request %d: headers %v", i, hdr)
+		}
+		if hdr.Get("Retry-After") != "" {
+			t.Fatalf("request %d: allowed response has Retry-After", i)
+		}
+	}
+
+	clock.set(15500 * time.Millisecond)
+	rec := serve(h, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Body.String() != "Too Many Requests\n" {
+		t.Fatalf("denied: %d %q", rec.Code, rec.Body)
+	}
+	// 44.5s rounded up
+	if got := rec.Header().Get("Retry-After"); got != "45" {
+		t.Fatalf("Retry-After = %q, want 45", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("RateLimit-Remaining = %q, want 0", got)
+	}
+}
+
+func TestMiddlewareDeniedBody(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(0), WithWindowSize(time.Minute))
+	h := Middleware(rl, WithDeniedBody("application/json", []byte(`{"error":"slow down"}`)))(okHandler)
+	rec := serve(h, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Body.String() != `{"error":"slow down"}` ||
+		rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("denied: %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+}
+
+func TestMiddlewareKeys(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(1), WithWindowSize(time.Minute))
+	key, err := ClientIP("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(rl, WithRequestKey(key))(okHandler)
+	request := func(remote, xff string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		if xff != "" {
+			r.Header.Set("X-Forwarded-For", xff)
+		}
+		return r
+	}
+
+	// through the trusted proxy, each client behind it has its own bucket
+	for _, client := range []string{"203.0.113.1", "203.0.113.2"} {
+		if rec := serve(h, request("10.0.0.1:1234", client+", 10.0.0.2")); rec.Code != http.StatusOK {
+			t.Fatalf("first request of %s: %d", client, rec.Code)
+		}
+	}
+	if rec := serve(h, request("10.0.0.1:1234", "203.0.113.1")); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request of 203.0.113.1: %d", rec.Code)
+	}
+	// an untrusted peer cannot pick its key with X-Forwarded-For
+	if rec := serve(h, request("198.51.100.7:1234", "203.0.113.9")); rec.Code != http.StatusOK {
+		t.Fatalf("first request of 198.51.100.7: %d", rec.Code)
+	}
+	if rec := serve(h, request("198.51.100.7:1234", "203.0.113.10")); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For got a fresh bucket: %d", rec.Code)
+	}
+
+	for name, tc := range map[string]struct {
+		key  RequestKeyFunc
+		set  func(r *http.Request)
+		want string
+		ok   bool
+	}{
+		"bearer":      {APIKey(), func(r *http.Request) { r.Header.Set("Authorization", "Bearer k1") }, "k1", true},
+		"x-api-key":   {APIKey(), func(r *http.Request) { r.Header.Set("X-API-Key", "k2") }, "k2", true},
+		"no api key":  {APIKey(), func(*http.Request) {}, "", false},
+		"header":      {HeaderKey("X-Tenant"), func(r *http.Request) { r.Header.Set("X-Tenant", "acme") }, "acme", true},
+		"route":       {RouteKey(), func(*http.Request) {}, "POST /search", true},
+		"combined":    {CombineKeys(APIKey(), RouteKey()), func(r *http.Request) { r.Header.Set("X-API-Key", "k3") }, "k3|POST /search", true},
+		"partial key": {CombineKeys(APIKey(), RouteKey()), func(*http.Request) {}, "", false},
+	} {
+		r := httptest.NewRequest("POST", "/search", nil)
+		tc.set(r)
+		if got, ok := tc.key(r); got != tc.want || ok != tc.ok {
+			t.Errorf("%s: key = %q, %v; want %q, %v", name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestMiddlewareUnkeyedRequestsAreUnlimited(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(0), WithWindowSize(time.Minute))
+	h := Middleware(rl, WithRequestKey(HeaderKey("X-Tenant")))(okHandler)
+	rec := serve(h, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("unkeyed request: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestMiddlewareFailsOpenOnStoreError(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(1), WithWindowSize(time.Minute), WithStore(failingStore{}))
+	h := Middleware(rl)(okHandler)
+	for i := 0; i < 3; i++ {
+		if rec := serve(h, httptest.NewRequest("GET", "/", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("request %d with the store down: %d", i, rec.Code)
+		}
+	}
+
+	h = Middleware(rl, WithErrorHandler(func(w http.ResponseWriter, _ *http.Request, _ error) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return false
+	}))(okHandler)
+	if rec := serve(h, httptest.NewRequest("GET", "/", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("with an error handler failing closed: %d", rec.Code)
+	}
+}
+
+func TestMiddlewareRejectsNegativeCost(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(10), WithWindowSize(time.Minute))
+	called := false
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })
+	cost := WithRequestCost(func(*http.Request) int { return -1 })
+	for name, h := range map[string]http.Handler{
+		"Middleware":       Middleware(rl, cost)(next),
+		"PolicyMiddleware": PolicyMiddleware(NewPolicyEngine(Rule{Name: "all", Limiter: rl, Key: GlobalKey()}), func(*http.Request) Attributes { return nil }, cost)(next),
+	} {
+		if rec := serve(h, httptest.NewRequest("GET", "/", nil)); rec.Code != http.StatusInternalServerError || called {
+			t.Errorf("%s: negative cost got %d, handler called %v", name, rec.Code, called)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Decision outcomes recorded by Metrics
+const (
+	OutcomeAllowed      = "allowed"
+	OutcomeDenied       = "denied"
+	OutcomeShadowDenied = "shadow_denied" // would have been denied, allowed by shadow mode
+	OutcomeError        = "error"
+)
+
+// Metrics counts decisions per limiter, key class and outcome. It is an http.Handler serving
+// the counters in the Prometheus text format.
+type Metrics struct {
+	mu       sync.RWMutex
+	counters map[metricKey]*atomic.Uint64
+}
+
+type metricKey struct {
+	limiter, class, outcome string
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{counters: make(map[metricKey]*atomic.Uint64)}
+}
+
+// Count returns the number of decisions recorded for limiter, class and outcome
+func (m *Metrics) Count(limiter, class, outcome string) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c, ok := m.counters[metricKey{limiter, class, outcome}]; ok {
+		return c.Load()
+	}
+	return 0
+}
+
+func (m *Metrics) inc(k metricKey) {
+	m.mu.RLock()
+	c, ok := m.counters[k]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if c, ok = m.counters[k]; !ok {
+			c = new(atomic.Uint64)
+			m.counters[k] = c
+		}
+		m.mu.Unlock()
+	}
+	c.Add(1)
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.RLock()
+	keys := make([]metricKey, 0, len(m.counters))
+	for k := range m.counters {
+		keys = append(keys, k)
+	}
+	m.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.limiter != b.limiter {
+			return a.limiter < b.limiter
+		}
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		return a.outcome < b.outcome
+	})
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# HELP ratelimit_decisions_total Rate limiter decisions by limiter, key class and outcome.\n")
+	bw.WriteString("# TYPE ratelimit_decisions_total counter\n")
+	for _, k := range keys {
+		bw.WriteString(`ratelimit_decisions_total{limiter="` + escapeLabel(k.limiter) +
+			`",class="` + escapeLabel(k.class) + `",outcome="` + k.outcome + `"} `)
+		bw.WriteString(strconv.FormatUint(m.Count(k.limiter, k.class, k.outcome), 10))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// ------------------- observed limiter ------------------
+
+// ObserveOption configures Observe
+type ObserveOption func(*observedLimiter)
+
+// WithShadowMode evaluates and records decisions but allows every request, to try out a new
+// limit before enforcing it. A request the limit would deny is recorded as shadow-denied and let
+// through; like a real denial it takes nothing from the quota, so the quota evolves as it would
+// under enforcement.
+func WithShadowMode() ObserveOption {
+	return func(o *observedLimiter) {
+		o.shadow = true
+	}
+}
+
+// WithKeyClass groups keys for metrics, e.g. by plan ("free", "pro") or key prefix.
+// Return a small set of values: every distinct class is its own time series.
+// Defaults to a single "default" class.
+func WithKeyClass(class func(key string) string) ObserveOption {
+	return func(o *observedLimiter) {
+		o.class = class
+	}
+}
+
+// WithDeniedLog logs one of every `every` denied (or shadow-denied) decisions to logger,
+// including the key, so hot keys show up without logging every request
+func WithDeniedLog(logger *log.Logger, every int) ObserveOption {
+	return func(o *observedLimiter) {
+		o.logger, o.logEvery = logger, uint64(max(every, 1))
+	}
+}
+
+type observedLimiter struct {
+	name     string
+	rl       RateLimiter
+	metrics  *Metrics
+	shadow   bool
+	class    func(key string) string
+	logger   *log.Logger
+	logEvery uint64
+	denied   atomic.Uint64
+}
+
+// Observe wraps rl so its decisions are counted in metrics under name. Works with any
+// RateLimiter. Decisions with cost 0 only inspect quota and are not counted.
+func Observe(name string, rl RateLimiter, metrics *Metrics, options ...ObserveOption) RateLimiter {
+	o := &observedLimiter{
+		name:    name,
+		rl:      rl,
+		metrics: metrics,
+		class:   func(string) string { return "default" },
+	}
+	for _, opt := range options {
+		opt(o)
+	}
+	return o
+}
+
+func (o *observedLimiter) AllowRequest(key string) bool {
+	return allowRequest(o, key)
+}
+
+func (o *observedLimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	d, err := o.rl.Decide(ctx, key, cost)
+	if cost == 0 {
+		return d, err
+	}
+	k := metricKey{limiter: o.name, class: o.class(key)}
+	switch {
+	case err != nil && !errors.Is(err, ErrCostExceedsLimit):
+		k.outcome = OutcomeError
+	case d.Allowed:
+		k.outcome = OutcomeAllowed
+	case o.shadow:
+		k.outcome = OutcomeShadowDenied
+		d.Allowed, d.RetryAfter, err = true, 0, nil
+	default:
+		k.outcome = OutcomeDenied
+	}
+	o.metrics.inc(k)
+	if (k.outcome == OutcomeDenied || k.outcome == OutcomeShadowDenied) && o.logger != nil {
+		if n := o.denied.Add(1); (n-1)%o.logEvery == 0 {
+			o.logger.Printf("rate limiter %s: %s key %q (class %s, limit %d, cost %d, sampled 1/%d)",
+				o.name, strings.ReplaceAll(k.outcome, "_", "-"), key, k.class, d.Limit, cost, o.logEvery)
+		}
+	}
+	return d, err
+}
+
+// shadowed reports limiters that never deny, so PolicyEngine doesn't enforce their quota
+func (o *observedLimiter) shadowed() bool { return o.shadow }
+
+// peek passes non-consuming checks through to the wrapped limiter when it supports them
+func (o *observedLimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	if pk, ok := o.rl.(peeker); ok {
+		return pk.peek(ctx, key, cost)
+	}
+	return Decision{}, errors.ErrUnsupported
+}
+
+// refund passes rollbacks through to the wrapped limiter when it supports them
+func (o *observedLimiter) refund(ctx context.Context, key string, n int) error {
+	if rf, ok := o.rl.(refunder); ok {
+		return rf.refund(ctx, key, n)
+	}
+	return nil
+}
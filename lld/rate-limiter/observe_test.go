@@ -0,0 +1,80 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShadowModeLetsDenialsThroughAndCountsThem(t *testing.T) {
+	metrics := NewMetrics()
+	inner := NewFixedWindowRatelimiter(WithMaxRequests(2), WithWindowSize(time.Minute), WithClock(newFakeClock().Now))
+	var logged bytes.Buffer
+	rl := Observe("search", inner, metrics, WithShadowMode(), WithDeniedLog(log.New(&logged, "", 0), 2))
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		d, err := rl.Decide(ctx, "u1", 1)
+		if err != nil || !d.Allowed || d.RetryAfter != 0 {
+			t.Fatalf("request %d in shadow mode: %+v, %v; want allowed", i, d, err)
+		}
+	}
+	if got := metrics.Count("search", "default", OutcomeAllowed); got != 2 {
+		t.Errorf("allowed = %d, want 2", got)
+	}
+	if got := metrics.Count("search", "default", OutcomeShadowDenied); got != 3 {
+		t.Errorf("shadow_denied = %d, want 3", got)
+	}
+	if got := metrics.Count("search", "default", OutcomeDenied); got != 0 {
+		t.Errorf("denied = %d in shadow mode", got)
+	}
+	// shadow denials took nothing from the quota, which is exhausted as it would be if enforced
+	if d, _ := inner.Decide(ctx, "u1", 0); d.Remaining != 0 {
+		t.Errorf("remaining %d after two allowed requests, want 0", d.Remaining)
+	}
+	// one of every two would-be denials is logged: the 1st and 3rd
+	if lines := strings.Count(logged.String(), "\n"); lines != 2 || !strings.Contains(logged.String(), `shadow-denied key "u1"`) {
+		t.Errorf("denied log:\n%s", logged.String())
+	}
+
+	// a policy reports the shadowed rule but does not enforce it
+	engine := NewPolicyEngine(Rule{Name: "search", Key: GlobalKey(), Limiter: rl})
+	pd, err := engine.Decide(ctx, Attributes{}, 1)
+	if err != nil || !pd.Allowed || len(pd.Rules) != 1 || !pd.Rules[0].Shadow {
+		t.Fatalf("policy with a shadowed rule: %+v, %v", pd, err)
+	}
+}
+
+func TestMetricsPrometheusExport(t *testing.T) {
+	metrics := NewMetrics()
+	clock := newFakeClock()
+	plan := WithKeyClass(func(key string) string { return strings.SplitN(key, ":", 2)[0] })
+	perUser := Observe("per-user", NewFixedWindowRatelimiter(WithMaxRequests(1), WithWindowSize(time.Minute), WithClock(clock.Now)), metrics, plan)
+	perTenant := Observe(`per-"tenant"`, NewFixedWindowRatelimiter(WithMaxRequests(1), WithWindowSize(time.Minute), WithStore(failingStore{})), metrics)
+	ctx := context.Background()
+	for _, key := range []string{"free:u1", "free:u1", "free:u1", "pro:u2", "pro:u2"} {
+		perUser.Decide(ctx, key, 1)
+	}
+	perUser.Decide(ctx, "pro:u3", 0) // inspecting quota is not counted
+	perTenant.Decide(ctx, "t1", 1)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q", ct)
+	}
+	want := `# HELP ratelimit_decisions_total Rate limiter decisions by limiter, key class and outcome.
+# TYPE ratelimit_decisions_total counter
+ratelimit_decisions_total{limiter="per-\"tenant\"",class="default",outcome="error"} 1
+ratelimit_decisions_total{limiter="per-user",class="free",outcome="allowed"} 1
+ratelimit_decisions_total{limiter="per-user",class="free",outcome="denied"} 2
+ratelimit_decisions_total{limiter="per-user",class="pro",outcome="allowed"} 1
+ratelimit_decisions_total{limiter="per-user",class="pro",outcome="denied"} 1
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("exported:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Option configures settings shared by all limiters
+type Option func(*limiterOptions)
+
+type limiterOptions struct {
+	maxRequests int
+	windowSize  time.Duration
+	store       Store
+	maxKeys     int
+	keyPrefix   string
+	now         func() time.Time
+}
+
+func WithMaxRequests(maxRequests int) Option {
+	return func(o *limiterOptions) {
+		o.maxRequests = maxRequests
+	}
+}
+
+func WithWindowSize(windowSize time.Duration) Option {
+	return func(o *limiterOptions) {
+		o.windowSize = windowSize
+	}
+}
+
+// WithStore keeps limiter state in store instead of a private MemoryStore.
+// Use a shared store (e.g. RedisStore) to enforce one limit across instances.
+func WithStore(store Store) Option {
+	return func(o *limiterOptions) {
+		o.store = store
+	}
+}
+
+// WithMaxKeys caps the keys tracked by the limiter's default MemoryStore, evicting the least
+// recently used. Ignored when WithStore is given; configure that store instead.
+func WithMaxKeys(maxKeys int) Option {
+	return func(o *limiterOptions) {
+		o.maxKeys = maxKeys
+	}
+}
+
+// WithClock replaces time.Now for the limiter and its default MemoryStore, e.g. with a fake clock
+func WithClock(now func() time.Time) Option {
+	return func(o *limiterOptions) {
+		o.now = now
+	}
+}
+
+// WithKeyPrefix namespaces the limiter's keys, needed when several limiters share a store.
+// Defaults to the algorithm name, e.g. "token_bucket:".
+func WithKeyPrefix(prefix string) Option {
+	return func(o *limiterOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// base holds the plumbing shared by the limiters: where state lives and what time it is
+type base struct {
+	store  Store
+	prefix string
+	now    func() time.Time
+}
+
+func newBase(defaultPrefix string, options []Option) (base, limiterOptions) {
+	o := limiterOptions{keyPrefix: defaultPrefix, now: time.Now}
+	for _, opt := range options {
+		opt(&o)
+	}
+	if o.store == nil {
+		o.store = NewMemoryStore(WithStoreMaxKeys(o.maxKeys), WithStoreClock(o.now))
+	}
+	return base{store: o.store, prefix: o.keyPrefix, now: o.now}, o
+}
+
+// decide runs step for cost units against key's state and validates cost
+func (b *base) decide(ctx context.Context, key string, cost int, ttl time.Duration, step func(state []byte, now time.Time, cost int) ([]byte, Decision)) (Decision, error) {
+	if cost < 0 {
+		return Decision{}, ErrInvalidCost
+	}
+	var d Decision
+	err := b.update(ctx, key, ttl, func(state []byte, now time.Time) []byte {
+		var next []byte
+		next, d = step(state, now, cost)
+		return next
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+	if cost > d.Limit {
+		return d, ErrCostExceedsLimit
+	}
+	return d, nil
+}
+
+// update runs step atomically against the stored state of key. step receives nil for a new key
+// and may run several times on store conflicts, so it must only write to its own locals.
+func (b *base) update(ctx context.Context, key string, ttl time.Duration, step func(state []byte, now time.Time) []byte) error {
+	return b.store.Update(ctx, b.prefix+key, ttl, func(current []byte) ([]byte, error) {
+		return step(current, b.now()), nil
+	})
+}
+
+// dryRun runs step for cost units against key's state without storing the result
+func (b *base) dryRun(ctx context.Context, key string, cost int, step func(state []byte, now time.Time, cost int) ([]byte, Decision)) (Decision, error) {
+	var d Decision
+	err := b.store.Update(ctx, b.prefix+key, 0, func(current []byte) ([]byte, error) {
+		_, d = step(current, b.now(), cost)
+		return nil, errDryRun
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return Decision{}, err
+	}
+	return d, nil
+}
+
+// errDryRun aborts a store update so that nothing is written
+var errDryRun = errors.New("rate limiter: dry run")
@@ -0,0 +1,213 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrRefund is matched (errors.Is) by the error PolicyEngine.Decide returns, together with the
+// denied PolicyDecision, when units taken from earlier rules could not be given back
+var ErrRefund = errors.New("rate limiter: refund failed")
+
+// Attributes describe a request for policy evaluation, e.g.
+// {"user": "u1", "tenant": "acme", "api_key": "k1", "endpoint": "GET /search"}
+type Attributes map[string]string
+
+// KeyFunc derives the bucket key for a request; ok=false means the rule does not apply
+type KeyFunc func(attrs Attributes) (key string, ok bool)
+
+// KeyBy keys a rule by the values of the given attributes, e.g. KeyBy("tenant", "endpoint").
+// The rule only applies to requests that carry all of them.
+func KeyBy(names ...string) KeyFunc {
+	return func(attrs Attributes) (string, bool) {
+		parts := make([]string, len(names))
+		for i, name := range names {
+			v, ok := attrs[name]
+			if !ok || v == "" {
+				return "", false
+			}
+			parts[i] = v
+		}
+		return strings.Join(parts, "|"), true
+	}
+}
+
+// GlobalKey applies a rule to every request with a single shared bucket
+func GlobalKey() KeyFunc {
+	return func(Attributes) (string, bool) { return "global", true }
+}
+
+// Rule is one limit of a policy, e.g. "100/min per user"
+type Rule struct {
+	Name    string
+	Limiter RateLimiter
+	Key     KeyFunc
+}
+
+// RuleDecision is the outcome of one matching rule
+type RuleDecision struct {
+	Rule     string
+	Key      string
+	Decision Decision
+	Shadow   bool // the rule's limiter runs in shadow mode (see WithShadowMode) and is not enforced
+}
+
+// PolicyDecision is the combined outcome of all matching rules
+type PolicyDecision struct {
+	Allowed  bool
+	DeniedBy string // name of the first rule that denied, empty if allowed
+	// Decision is the most restrictive view across rules: smallest Remaining (with its Limit),
+	// latest ResetAt and, when denied, the longest RetryAfter
+	Decision Decision
+	Rules    []RuleDecision
+}
+
+// shadower is implemented by limiters wrapped with WithShadowMode
+type shadower interface {
+	shadowed() bool
+}
+
+// refunder is implemented by every limiter in this package so a policy can undo a consumption
+type refunder interface {
+	refund(ctx context.Context, key string, n int) error
+}
+
+// peeker is implemented by every limiter in this package: peek is Decide without consuming
+type peeker interface {
+	peek(ctx context.Context, key string, cost int) (Decision, error)
+}
+
+// PolicyEngine enforces several limits at once, e.g. "100/min per user AND 10k/min per tenant
+// AND 1M/min globally". A request is allowed only if every matching rule allows it, and units
+// are consumed from all matching buckets or none:
+//  1. every rule is checked with cost 0; if any lacks capacity the request is denied untouched
+//  2. units are consumed rule by rule; if a rule denies because a concurrent request got there
+//     first, units already taken from earlier rules are refunded
+//
+// Limiters from outside this package cannot be refunded, so for them step 2 is best effort, and
+// their denials in step 1 carry no RetryAfter.
+type PolicyEngine struct {
+	rules []Rule
+}
+
+func NewPolicyEngine(rules ...Rule) *PolicyEngine {
+	return &PolicyEngine{rules: rules}
+}
+
+// Decide evaluates the policy for a request costing cost units
+func (e *PolicyEngine) Decide(ctx context.Context, attrs Attributes, cost int) (PolicyDecision, error) {
+	if cost < 0 {
+		return PolicyDecision{}, ErrInvalidCost
+	}
+	type match struct {
+		rule Rule
+		key  string
+	}
+	var matches []match
+	for _, r := range e.rules {
+		if key, ok := r.Key(attrs); ok {
+			matches = append(matches, match{r, key})
+		}
+	}
+
+	// phase 1: check every bucket without consuming
+	pd := PolicyDecision{Allowed: true}
+	for _, m := range matches {
+		d, err := m.rule.Limiter.Decide(ctx, m.key, 0)
+		if err != nil {
+			return PolicyDecision{}, err
+		}
+		sh, ok := m.rule.Limiter.(shadower)
+		shadow := ok && sh.shadowed()
+		if d.Remaining < cost && !shadow {
+			d.Allowed = false
+			if cost <= d.Limit {
+				d.RetryAfter = retryAfter(ctx, m.rule.Limiter, m.key, cost)
+			}
+			if pd.Allowed {
+				pd.Allowed, pd.DeniedBy = false, m.rule.Name
+			}
+		}
+		pd.Rules = append(pd.Rules, RuleDecision{Rule: m.rule.Name, Key: m.key, Decision: d, Shadow: shadow})
+	}
+	if !pd.Allowed || cost == 0 {
+		pd.aggregate()
+		return pd, nil
+	}
+
+	// phase 2: consume from every bucket, rolling back on a lost race
+	for i, m := range matches {
+		d, err := m.rule.Limiter.Decide(ctx, m.key, cost)
+		if err == nil && d.Allowed {
+			pd.Rules[i].Decision = d
+			continue
+		}
+		var refundErrs []error
+		for _, done := range matches[:i] {
+			if rf, ok := done.rule.Limiter.(refunder); ok {
+				if rerr := rf.refund(ctx, done.key, cost); rerr != nil {
+					refundErrs = append(refundErrs, fmt.Errorf("rule %q: %w", done.rule.Name, rerr))
+				}
+			}
+		}
+		if err != nil {
+			return PolicyDecision{}, err
+		}
+		pd.Rules[i].Decision = d
+		pd.Allowed, pd.DeniedBy = false, m.rule.Name
+		if len(refundErrs) > 0 {
+			pd.aggregate()
+			return pd, fmt.Errorf("%w: %w", ErrRefund, errors.Join(refundErrs...))
+		}
+		break
+	}
+	pd.aggregate()
+	return pd, nil
+}
+
+// Allow is Decide for a single unit, failing open on store errors
+func (e *PolicyEngine) Allow(attrs Attributes) bool {
+	pd, err := e.Decide(context.Background(), attrs, 1)
+	if err != nil && !errors.Is(err, ErrRefund) {
+		return failOpen(err)
+	}
+	return pd.Allowed
+}
+
+// retryAfter asks a rule lacking capacity how long until cost units are available; the cost-0
+// check carries no RetryAfter. Nothing is consumed: limiters that cannot peek report 0.
+func retryAfter(ctx context.Context, rl RateLimiter, key string, cost int) time.Duration {
+	pk, ok := rl.(peeker)
+	if !ok {
+		return 0
+	}
+	d, err := pk.peek(ctx, key, cost)
+	if err != nil || d.Allowed {
+		return 0
+	}
+	return d.RetryAfter
+}
+
+func (pd *PolicyDecision) aggregate() {
+	first := true
+	for _, rd := range pd.Rules {
+		if rd.Shadow {
+			continue
+		}
+		d := rd.Decision
+		if first || d.Remaining < pd.Decision.Remaining {
+			pd.Decision.Remaining, pd.Decision.Limit = d.Remaining, d.Limit
+		}
+		if d.ResetAt.After(pd.Decision.ResetAt) {
+			pd.Decision.ResetAt = d.ResetAt
+		}
+		if !d.Allowed && d.RetryAfter > pd.Decision.RetryAfter {
+			pd.Decision.RetryAfter = d.RetryAfter
+		}
+		first = false
+	}
+	pd.Decision.Allowed = pd.Allowed
+}
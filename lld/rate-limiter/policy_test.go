@@ -0,0 +1,90 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// externalLimiter hides the unexported methods of the wrapped limiter, like a limiter from
+// another package, and counts the units its Decide is asked for
+type externalLimiter struct {
+	RateLimiter
+	asked int
+}
+
+func (l *externalLimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	l.asked += cost
+	return l.RateLimiter.Decide(ctx, key, cost)
+}
+
+// scriptedLimiter always reports remaining units on cost-0 checks, then allows or denies
+type scriptedLimiter struct {
+	allow     bool
+	refundErr error
+}
+
+func (l *scriptedLimiter) AllowRequest(string) bool { return l.allow }
+
+func (l *scriptedLimiter) Decide(_ context.Context, _ string, cost int) (Decision, error) {
+	return Decision{Allowed: cost == 0 || l.allow, Limit: 10, Remaining: 5}, nil
+}
+
+func (l *scriptedLimiter) refund(context.Context, string, int) error { return l.refundErr }
+
+func TestPolicyRetryAfterDoesNotConsume(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewTokenBucketRatelimiter(2, time.Second, WithClock(clock.Now))
+	e := NewPolicyEngine(Rule{Name: "user", Limiter: rl, Key: KeyBy("user")})
+	attrs := Attributes{"user": "u1"}
+	ctx := context.Background()
+	if pd, err := e.Decide(ctx, attrs, 2); err != nil || !pd.Allowed {
+		t.Fatalf("first Decide = %+v, %v", pd, err)
+	}
+	for i := 0; i < 3; i++ {
+		pd, err := e.Decide(ctx, attrs, 1)
+		if err != nil || pd.Allowed || pd.Decision.RetryAfter != 500*time.Millisecond {
+			t.Fatalf("Decide on an empty bucket = %+v, %v; want denied with RetryAfter 500ms", pd, err)
+		}
+	}
+	clock.set(500 * time.Millisecond)
+	if pd, err := e.Decide(ctx, attrs, 1); err != nil || !pd.Allowed {
+		t.Fatalf("denials consumed units: Decide after RetryAfter = %+v, %v", pd, err)
+	}
+}
+
+func TestPolicyNeverConsumesFromExternalLimiterWhenDenied(t *testing.T) {
+	ext := &externalLimiter{RateLimiter: NewTokenBucketRatelimiter(1, time.Hour)}
+	e := NewPolicyEngine(Rule{Name: "ext", Limiter: ext, Key: GlobalKey()})
+	if pd, err := e.Decide(context.Background(), nil, 2); err != nil || pd.Allowed {
+		t.Fatalf("Decide(2) over a limit of 1 = %+v, %v", pd, err)
+	}
+	if pd, err := e.Decide(context.Background(), nil, 1); err != nil || !pd.Allowed {
+		t.Fatalf("Decide(1) = %+v, %v", pd, err)
+	}
+	if pd, err := e.Decide(context.Background(), nil, 1); err != nil || pd.Allowed || pd.Decision.RetryAfter != 0 {
+		t.Fatalf("Decide(1) on an empty bucket = %+v, %v; want denied without RetryAfter", pd, err)
+	}
+	if ext.asked != 1 {
+		t.Fatalf("external limiter was asked for %d units, want only the allowed 1", ext.asked)
+	}
+}
+
+func TestPolicyReportsRefundFailures(t *testing.T) {
+	storeDown := errors.New("store down")
+	e := NewPolicyEngine(
+		Rule{Name: "a", Limiter: &scriptedLimiter{allow: true, refundErr: storeDown}, Key: GlobalKey()},
+		Rule{Name: "b", Limiter: &scriptedLimiter{allow: false}, Key: GlobalKey()},
+	)
+	pd, err := e.Decide(context.Background(), nil, 1)
+	if !errors.Is(err, ErrRefund) || !errors.Is(err, storeDown) {
+		t.Fatalf("error = %v, want ErrRefund wrapping the refund error", err)
+	}
+	if pd.Allowed || pd.DeniedBy != "b" {
+		t.Fatalf("decision = %+v, want denied by b", pd)
+	}
+	if e.Allow(nil) {
+		t.Fatal("Allow must not fail open on a refund error")
+	}
+}
@@ -1,5 +1,33 @@
 package rate_limiter
 
+import (
+	"context"
+	"errors"
+)
+
 type RateLimiter interface {
 	AllowRequest(string) bool
+	// Decide consumes cost units for key if they are available (all or nothing) and reports the
+	// resulting quota. cost 0 only inspects the current quota.
+	Decide(ctx context.Context, key string, cost int) (Decision, error)
+}
+
+// allowRequest is AllowRequest in terms of Decide. It fails open if the store is unavailable.
+func allowRequest(rl RateLimiter, key string) bool {
+	d, err := rl.Decide(context.Background(), key, 1)
+	if err != nil {
+		return failOpen(err)
+	}
+	return d.Allowed
 }
+
+// failOpen reports whether a Decide error should let the request through: store and transport
+// errors do, a bad cost or a cost above the limit never does
+func failOpen(err error) bool {
+	return !errors.Is(err, ErrInvalidCost) && !errors.Is(err, ErrCostExceedsLimit)
+}
+
+var (
+	_ Reserver = (*tokenBucketRatelimiter)(nil)
+	_ Reserver = (*LeakyBucketRatelimiter)(nil)
+)
@@ -0,0 +1,31 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingStore is a Store that is always unavailable
+type failingStore struct{}
+
+func (failingStore) Update(context.Context, string, time.Duration, func([]byte) ([]byte, error)) error {
+	return errors.New("store down")
+}
+
+func TestAllowRequestDeniesCostAboveLimit(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(0), WithWindowSize(time.Minute))
+	if rl.AllowRequest("k") {
+		t.Fatal("a limit of 0 must deny every request")
+	}
+}
+
+func TestAllowRequestFailsOpenOnStoreError(t *testing.T) {
+	rl := NewFixedWindowRatelimiter(WithMaxRequests(1), WithWindowSize(time.Minute), WithStore(failingStore{}))
+	for i := 0; i < 3; i++ {
+		if !rl.AllowRequest("k") {
+			t.Fatal("store errors must let requests through")
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisStore is a Store backed by any Redis-protocol server (Redis, KeyDB, or RESPServer in this
+// package). Update is an optimistic transaction: WATCH key, GET, run fn, then MULTI/SET/EXEC.
+// EXEC aborts if another instance wrote the key in between, and the update is retried.
+// Limiters still read time from the local clock, so instances should be NTP-synced.
+type RedisStore struct {
+	addr        string
+	pool        chan *redisConn
+	dialTimeout time.Duration
+	maxRetries  int
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+	wr   *bufio.Writer
+}
+
+func NewRedisStore(addr string) *RedisStore {
+	return &RedisStore{
+		addr:        addr,
+		pool:        make(chan *redisConn, 16),
+		dialTimeout: 2 * time.Second,
+		maxRetries:  32,
+	}
+}
+
+func (s *RedisStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.update(ctx, c, key, ttl, fn)
+	if fe, ok := err.(fnError); ok {
+		s.release(c)
+		return fe.err
+	}
+	if err != nil {
+		// connection state is unknown (e.g. mid-transaction), don't reuse it
+		c.conn.Close()
+		return err
+	}
+	s.release(c)
+	return nil
+}
+
+// fnError marks errors returned by the caller's fn, which leave the connection usable
+type fnError struct{ err error }
+
+func (e fnError) Error() string { return e.err.Error() }
+
+func (s *RedisStore) update(ctx context.Context, c *redisConn, key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error {
+	for attempt := 0; attempt < s.maxRetries; attempt++ {
+		if _, err := c.do("WATCH", []byte(key)); err != nil {
+			return err
+		}
+		reply, err := c.do("GET", []byte(key))
+		if err != nil {
+			return err
+		}
+		current, _ := reply.([]byte)
+		next, err := fn(current)
+		if err != nil {
+			if _, uerr := c.do("UNWATCH"); uerr != nil {
+				return uerr
+			}
+			return fnError{err}
+		}
+
+		if _, err := c.do("MULTI"); err != nil {
+			return err
+		}
+		setArgs := [][]byte{[]byte(key), next}
+		if ttl > 0 {
+			setArgs = append(setArgs, []byte("PX"), []byte(strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)))
+		}
+		if _, err := c.do("SET", setArgs...); err != nil {
+			return err
+		}
+		reply, err = c.do("EXEC")
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			return nil
+		}
+		// nil reply: key changed since WATCH, back off with jitter and retry with the fresh value
+		backoff := time.Duration(rand.Int63n(int64(100*time.Microsecond) << min(attempt, 8)))
+		select {
+		case <-ctx.Done():
+			return fnError{ctx.Err()}
+		case <-time.After(backoff):
+		}
+	}
+	return ErrStoreConflict
+}
+
+// conn takes a pooled connection or dials a new one, applying ctx's deadline
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, error) {
+	var c *redisConn
+	select {
+	case c = <-s.pool:
+	default:
+		d := net.Dialer{Timeout: s.dialTimeout}
+		conn, err := d.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			return nil, err
+		}
+		c = &redisConn{conn: conn, rd: bufio.NewReader(conn), wr: bufio.NewWriter(conn)}
+	}
+	deadline, _ := ctx.Deadline() // zero clears any previous deadline
+	c.conn.SetDeadline(deadline)
+	return c, nil
+}
+
+func (s *RedisStore) release(c *redisConn) {
+	select {
+	case s.pool <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+// Close closes idle pooled connections
+func (s *RedisStore) Close() {
+	for {
+		select {
+		case c := <-s.pool:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do sends one command and returns its reply; server errors are returned as errors
+func (c *redisConn) do(cmd string, args ...[]byte) (any, error) {
+	if err := writeCommand(c.wr, append([][]byte{[]byte(cmd)}, args...)...); err != nil {
+		return nil, err
+	}
+	reply, err := readReply(c.rd)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(respError); ok {
+		return nil, fmt.Errorf("redis %s: %w", cmd, e)
+	}
+	return reply, nil
+}
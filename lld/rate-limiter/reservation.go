@@ -0,0 +1,109 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWaitExceedsDeadline = errors.New("rate limiter: wait would exceed context deadline")
+	// ErrReservationDebt is returned by Wait when earlier reservations already hold a full bucket of debt
+	ErrReservationDebt = errors.New("rate limiter: too much capacity already reserved")
+)
+
+// Reserver is implemented by limiters that can hand out future capacity (token and leaky bucket)
+type Reserver interface {
+	RateLimiter
+	// AllowN consumes n units if available now. Fails open if the store is unavailable, but a
+	// negative n or one above the limit is always denied.
+	AllowN(key string, n int) bool
+	// Reserve takes n units now, going into debt if needed, and returns how long the caller must
+	// wait before acting. The debt is bounded by one bucket; beyond that the reservation is not OK.
+	Reserve(ctx context.Context, key string, n int) (*Reservation, error)
+	// Wait blocks until n units are available or ctx ends
+	Wait(ctx context.Context, key string, n int) error
+}
+
+// Reservation is capacity taken from a limiter for use at TimeToAct
+type Reservation struct {
+	ok        bool
+	tokens    int
+	timeToAct time.Time
+	refund    func(ctx context.Context) error
+	once      sync.Once
+}
+
+// OK reports whether the limiter can provide the units within the allowed debt.
+// A reservation that is not OK holds nothing.
+func (r *Reservation) OK() bool { return r.ok }
+
+// Delay is how long to wait from now before acting
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(time.Now())
+}
+
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return 0
+	}
+	return max(r.timeToAct.Sub(now), 0)
+}
+
+func (r *Reservation) TimeToAct() time.Time { return r.timeToAct }
+
+// Tokens is the number of units reserved
+func (r *Reservation) Tokens() int { return r.tokens }
+
+// Cancel gives the reserved units back to the limiter. Call it only if the action will not happen;
+// cancelling more than once is a no-op.
+func (r *Reservation) Cancel() {
+	r.CancelContext(context.Background())
+}
+
+func (r *Reservation) CancelContext(ctx context.Context) error {
+	var err error
+	r.once.Do(func() {
+		if r.ok && r.refund != nil {
+			err = r.refund(ctx)
+		}
+	})
+	return err
+}
+
+func allowN(rl RateLimiter, key string, n int) bool {
+	d, err := rl.Decide(context.Background(), key, n)
+	if err != nil {
+		return failOpen(err)
+	}
+	return d.Allowed
+}
+
+// wait implements Reserver.Wait on top of Reserve
+func wait(ctx context.Context, rl Reserver, key string, n int, now func() time.Time) error {
+	r, err := rl.Reserve(ctx, key, n)
+	if err != nil {
+		return err
+	}
+	if !r.OK() {
+		return ErrReservationDebt
+	}
+	delay := r.DelayFrom(now())
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(r.TimeToAct()) {
+		r.Cancel()
+		return ErrWaitExceedsDeadline
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,24 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowNDeniesInvalidCosts(t *testing.T) {
+	const limit = 5
+	for name, rl := range map[string]Reserver{
+		"token bucket": NewTokenBucketRatelimiter(limit, time.Second),
+		"leaky bucket": NewLeakyBucketRatelimiter(limit, 1),
+	} {
+		if rl.AllowN("k", limit+1) {
+			t.Errorf("%s: AllowN(limit+1) = true", name)
+		}
+		if rl.AllowN("k", -1) {
+			t.Errorf("%s: AllowN(-1) = true", name)
+		}
+		if !rl.AllowN("k", limit) {
+			t.Errorf("%s: AllowN(limit) on a full bucket = false", name)
+		}
+	}
+}
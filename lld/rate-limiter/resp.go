@@ -0,0 +1,143 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Minimal RESP2 (Redis serialization protocol) codec shared by RedisStore and RESPServer.
+// Replies decode to: string (simple string), respError, int64, []byte or nil (bulk),
+// []any or nil (array).
+
+type respError string
+
+func (e respError) Error() string { return string(e) }
+
+// Limits on lengths announced by the peer, so a single header line cannot make us allocate
+// gigabytes. Limiter state and commands are tiny, so these are far below Redis' own limits.
+const (
+	maxBulkLen   = 1 << 20 // bytes in a bulk string
+	maxArrayLen  = 1 << 16 // elements in an array
+	maxReplyNest = 8       // nested array depth
+)
+
+// protocolError is a malformed or oversized message; RESPServer reports it before hanging up
+type protocolError string
+
+func (e protocolError) Error() string { return "resp: " + string(e) }
+
+func writeCommand(w *bufio.Writer, args ...[]byte) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		writeBulk(w, a)
+	}
+	return w.Flush()
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func writeSimple(w *bufio.Writer, s string) { w.WriteString("+" + s + "\r\n") }
+func writeError(w *bufio.Writer, s string)  { w.WriteString("-" + s + "\r\n") }
+func writeInt(w *bufio.Writer, n int64)     { w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n") }
+
+func readReply(r *bufio.Reader) (any, error) {
+	return readValue(r, 0)
+}
+
+func readValue(r *bufio.Reader, depth int) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, protocolError("empty line")
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, protocolError(fmt.Sprintf("bad bulk length %q", line))
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if n > maxBulkLen {
+			return nil, protocolError(fmt.Sprintf("bulk length %d exceeds %d", n, maxBulkLen))
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, protocolError(fmt.Sprintf("bad array length %q", line))
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if n > maxArrayLen {
+			return nil, protocolError(fmt.Sprintf("array length %d exceeds %d", n, maxArrayLen))
+		}
+		if depth >= maxReplyNest {
+			return nil, protocolError("arrays nested too deeply")
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readValue(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, protocolError(fmt.Sprintf("unexpected type %q", line[0]))
+}
+
+// readCommand reads a client command, an array of bulk strings
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	v, err := readReply(r)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]any)
+	if !ok || len(arr) == 0 {
+		return nil, protocolError("expected command array")
+	}
+	args := make([][]byte, len(arr))
+	for i, a := range arr {
+		b, ok := a.([]byte)
+		if !ok {
+			return nil, protocolError("expected bulk string argument")
+		}
+		args[i] = b
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("malformed line")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,247 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RESPServer serves a MemoryStore over the Redis protocol so several limiter processes can share
+// state through RedisStore without running Redis. It supports the subset RedisStore needs plus a
+// few basics: PING, GET, SET [EX|PX], DEL, WATCH, UNWATCH, MULTI, EXEC, DISCARD, QUIT.
+type RESPServer struct {
+	store *MemoryStore
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+}
+
+func NewRESPServer(store *MemoryStore) *RESPServer {
+	return &RESPServer{store: store, conns: make(map[net.Conn]struct{})}
+}
+
+// ListenAndServe listens on addr (e.g. "127.0.0.1:6380") and serves until Close
+func (srv *RESPServer) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+func (srv *RESPServer) Serve(ln net.Listener) error {
+	srv.mu.Lock()
+	srv.ln = ln
+	srv.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		srv.mu.Lock()
+		srv.conns[conn] = struct{}{}
+		srv.mu.Unlock()
+		go srv.serveConn(conn)
+	}
+}
+
+// Addr returns the listening address, nil before Serve
+func (srv *RESPServer) Addr() net.Addr {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.ln == nil {
+		return nil
+	}
+	return srv.ln.Addr()
+}
+
+// Close stops accepting and drops open connections
+func (srv *RESPServer) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	if srv.ln == nil {
+		return nil
+	}
+	return srv.ln.Close()
+}
+
+// respSession is the per-connection transaction state
+type respSession struct {
+	watched map[string]uint64 // key -> version at WATCH time
+	queued  [][][]byte        // commands queued after MULTI, nil when not in MULTI
+	inMulti bool
+}
+
+func (srv *RESPServer) serveConn(conn net.Conn) {
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.conns, conn)
+		srv.mu.Unlock()
+		conn.Close()
+	}()
+	rd, wr := bufio.NewReader(conn), bufio.NewWriter(conn)
+	sess := &respSession{watched: make(map[string]uint64)}
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				writeError(wr, "ERR Protocol error: "+string(perr))
+				wr.Flush()
+			}
+			return
+		}
+		if quit := srv.handle(wr, sess, args); quit {
+			wr.Flush()
+			return
+		}
+		if wr.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (srv *RESPServer) handle(wr *bufio.Writer, sess *respSession, args [][]byte) (quit bool) {
+	cmd := strings.ToUpper(string(args[0]))
+	switch cmd {
+	case "QUIT":
+		writeSimple(wr, "OK")
+		return true
+	case "MULTI":
+		if sess.inMulti {
+			writeError(wr, "ERR MULTI calls can not be nested")
+			return false
+		}
+		sess.inMulti = true
+		writeSimple(wr, "OK")
+	case "DISCARD":
+		if !sess.inMulti {
+			writeError(wr, "ERR DISCARD without MULTI")
+			return false
+		}
+		sess.reset()
+		writeSimple(wr, "OK")
+	case "EXEC":
+		if !sess.inMulti {
+			writeError(wr, "ERR EXEC without MULTI")
+			return false
+		}
+		srv.exec(wr, sess)
+		sess.reset()
+	case "WATCH":
+		if sess.inMulti {
+			writeError(wr, "ERR WATCH inside MULTI is not allowed")
+			return false
+		}
+		for _, k := range args[1:] {
+			_, ver := srv.store.Get(string(k))
+			sess.watched[string(k)] = ver
+		}
+		writeSimple(wr, "OK")
+	case "UNWATCH":
+		clear(sess.watched)
+		writeSimple(wr, "OK")
+	default:
+		if sess.inMulti {
+			sess.queued = append(sess.queued, args)
+			writeSimple(wr, "QUEUED")
+			return false
+		}
+		srv.store.mu.Lock()
+		srv.execLocked(wr, args)
+		srv.store.mu.Unlock()
+	}
+	return false
+}
+
+// exec runs the queued transaction atomically, or replies nil if a watched key changed
+func (srv *RESPServer) exec(wr *bufio.Writer, sess *respSession) {
+	s := srv.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, ver := range sess.watched {
+		current := uint64(0)
+		if e := s.live(key); e != nil {
+			current = e.version
+		}
+		if current != ver {
+			wr.WriteString("*-1\r\n")
+			return
+		}
+	}
+	wr.WriteString("*" + strconv.Itoa(len(sess.queued)) + "\r\n")
+	for _, args := range sess.queued {
+		srv.execLocked(wr, args)
+	}
+}
+
+// execLocked runs a data command and writes its reply. Caller must hold store.mu.
+func (srv *RESPServer) execLocked(wr *bufio.Writer, args [][]byte) {
+	s := srv.store
+	switch strings.ToUpper(string(args[0])) {
+	case "PING":
+		writeSimple(wr, "PONG")
+	case "GET":
+		if len(args) != 2 {
+			writeError(wr, "ERR wrong number of arguments for 'get' command")
+			return
+		}
+		if e := s.live(string(args[1])); e != nil {
+			writeBulk(wr, e.value)
+			return
+		}
+		writeBulk(wr, nil)
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			writeError(wr, "ERR syntax error")
+			return
+		}
+		var ttl time.Duration
+		if len(args) == 5 {
+			n, err := strconv.ParseInt(string(args[4]), 10, 64)
+			if err != nil || n <= 0 {
+				writeError(wr, "ERR invalid expire time in 'set' command")
+				return
+			}
+			switch strings.ToUpper(string(args[3])) {
+			case "PX":
+				ttl = time.Duration(n) * time.Millisecond
+			case "EX":
+				ttl = time.Duration(n) * time.Second
+			default:
+				writeError(wr, "ERR syntax error")
+				return
+			}
+		}
+		s.set(string(args[1]), append([]byte(nil), args[2]...), ttl)
+		writeSimple(wr, "OK")
+	case "DEL":
+		n := int64(0)
+		for _, k := range args[1:] {
+			if s.live(string(k)) != nil {
+				s.remove(s.entries[string(k)])
+				n++
+			}
+		}
+		writeInt(wr, n)
+	default:
+		writeError(wr, "ERR unknown command '"+string(args[0])+"'")
+	}
+}
+
+func (sess *respSession) reset() {
+	sess.inMulti = false
+	sess.queued = nil
+	clear(sess.watched)
+}
@@ -0,0 +1,44 @@
+package rate_limiter
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadReplyRejectsOversizedLengths(t *testing.T) {
+	for _, in := range []string{
+		"$2000000000\r\n",
+		"*2000000000\r\n",
+		strings.Repeat("*1\r\n", maxReplyNest+1) + ":1\r\n",
+	} {
+		_, err := readReply(bufio.NewReader(strings.NewReader(in)))
+		var perr protocolError
+		if !errors.As(err, &perr) {
+			t.Errorf("readReply(%q) error = %v, want a protocol error", in[:12], err)
+		}
+	}
+}
+
+func TestRESPServerReportsProtocolError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewRESPServer(NewMemoryStore())
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("*1\r\n$2000000000\r\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "-ERR Protocol error") {
+		t.Fatalf("got %q, %v; want a protocol error reply", line, err)
+	}
+}
@@ -0,0 +1,111 @@
+package rate_limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// slidingWindowCounterRatelimiter approximates a sliding window with two fixed windows:
+// the count of the previous window is weighted by how much of it still overlaps the sliding
+// window, plus the count of the current window. O(1) state per key, no 2x burst at window edges.
+type slidingWindowCounterRatelimiter struct {
+	base
+	maxRequests int
+	windowSize  time.Duration
+}
+
+// sliding window counter state: [previousCount, currentCount, currentWindowStart (unix nanos)]
+// windows are aligned to multiples of windowSize since the epoch
+
+// NewSlidingWindowCounterRatelimiter fails for a non-positive windowSize
+func NewSlidingWindowCounterRatelimiter(maxRequests int, windowSize time.Duration, options ...Option) (*slidingWindowCounterRatelimiter, error) {
+	if windowSize <= 0 {
+		return nil, fmt.Errorf("rate limiter: sliding window counter needs a positive window, got %v", windowSize)
+	}
+	b, _ := newBase("sliding_window_counter:", options)
+	return &slidingWindowCounterRatelimiter{
+		base:        b,
+		maxRequests: maxRequests,
+		windowSize:  windowSize,
+	}, nil
+}
+
+func (rl *slidingWindowCounterRatelimiter) AllowRequest(key string) bool {
+	return allowRequest(rl, key)
+}
+
+func (rl *slidingWindowCounterRatelimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	// after two windows both counters are stale
+	return rl.decide(ctx, key, cost, 2*rl.windowSize, rl.step)
+}
+
+func (rl *slidingWindowCounterRatelimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.dryRun(ctx, key, cost, rl.step)
+}
+
+func (rl *slidingWindowCounterRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	// time.Truncate aligns to the zero time, not the Unix epoch
+	nanos := currentTime.UnixNano()
+	windowStart := time.Unix(0, nanos-nanos%int64(rl.windowSize))
+	prev, curr := 0, 0
+	if w, ok := decodeState(state, 3); ok {
+		switch storedStart := time.Unix(0, int64(w[2])); {
+		case storedStart.Equal(windowStart):
+			prev, curr = int(w[0]), int(w[1])
+		case storedStart.Add(rl.windowSize).Equal(windowStart):
+			prev = int(w[1])
+		}
+	}
+
+	elapsed := float64(currentTime.Sub(windowStart)) / float64(rl.windowSize)
+	estimate := float64(prev)*(1-elapsed) + float64(curr)
+	d := Decision{Limit: rl.maxRequests}
+	d.Allowed = estimate+float64(cost) <= float64(rl.maxRequests)
+	if d.Allowed {
+		curr += cost
+		estimate += float64(cost)
+	} else if cost <= rl.maxRequests {
+		d.RetryAfter = rl.retryAfter(prev, curr, cost, windowStart, currentTime)
+	}
+	d.Remaining = max(int(math.Floor(float64(rl.maxRequests)-estimate)), 0)
+	// the estimate reaches zero once both counted windows have slid out
+	switch {
+	case curr > 0:
+		d.ResetAt = windowStart.Add(2 * rl.windowSize)
+	case prev > 0:
+		d.ResetAt = windowStart.Add(rl.windowSize)
+	default:
+		d.ResetAt = currentTime
+	}
+	return encodeState(uint64(prev), uint64(curr), uint64(windowStart.UnixNano())), d
+}
+
+// retryAfter finds when the weighted estimate leaves room for cost
+func (rl *slidingWindowCounterRatelimiter) retryAfter(prev, curr, cost int, windowStart, currentTime time.Time) time.Duration {
+	room := float64(rl.maxRequests - curr - cost)
+	if room >= 0 && prev > 0 {
+		// still in this window: prev*(1-f) <= room
+		f := 1 - room/float64(prev)
+		return windowStart.Add(time.Duration(math.Ceil(f * float64(rl.windowSize)))).Sub(currentTime)
+	}
+	// next window: curr becomes the previous count, curr*(1-f) <= max-cost
+	next := windowStart.Add(rl.windowSize)
+	f := 0.0
+	if curr > 0 {
+		f = max(1-float64(rl.maxRequests-cost)/float64(curr), 0)
+	}
+	return next.Add(time.Duration(math.Ceil(f * float64(rl.windowSize)))).Sub(currentTime)
+}
+
+// refund gives back n requests counted in the current window
+func (rl *slidingWindowCounterRatelimiter) refund(ctx context.Context, key string, n int) error {
+	return rl.update(ctx, key, 2*rl.windowSize, func(state []byte, currentTime time.Time) []byte {
+		w, ok := decodeState(state, 3)
+		if !ok {
+			return state
+		}
+		return encodeState(w[0], uint64(max(int(w[1])-n, 0)), w[2])
+	})
+}
@@ -1,37 +1,68 @@
 package rate_limiter
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type slidingWindowRatelimiter struct {
+	base
 	maxRequests int
 	windowSize  time.Duration
-	requests    map[string][]time.Time
 }
 
-func NewSlidingWindowRatelimiter(maxRequests int, windowSize time.Duration) *slidingWindowRatelimiter {
+// sliding window state: timestamps (unix nanos) of the requests inside the window, oldest first
+
+func NewSlidingWindowRatelimiter(maxRequests int, windowSize time.Duration, options ...Option) *slidingWindowRatelimiter {
+	b, _ := newBase("sliding_window:", options)
 	return &slidingWindowRatelimiter{
+		base:        b,
 		maxRequests: maxRequests,
 		windowSize:  windowSize,
-		requests:    make(map[string][]time.Time),
 	}
 }
 
 func (rl *slidingWindowRatelimiter) AllowRequest(key string) bool {
-	currentTime := time.Now()
-	queue, exists := rl.requests[key]
-	if !exists {
-		rl.requests[key] = make([]time.Time, 0)
-		queue = rl.requests[key]
-	}
+	return allowRequest(rl, key)
+}
+
+func (rl *slidingWindowRatelimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.decide(ctx, key, cost, rl.windowSize, rl.step)
+}
+
+func (rl *slidingWindowRatelimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.dryRun(ctx, key, cost, rl.step)
+}
+
+func (rl *slidingWindowRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	queue := decodeWords(state)
 	i := 0
-	for i < len(queue) && currentTime.Sub(queue[i]) > rl.windowSize {
+	for i < len(queue) && currentTime.Sub(time.Unix(0, int64(queue[i]))) > rl.windowSize {
 		i++
 	}
 	queue = queue[i:]
-	if len(queue) < rl.maxRequests {
-		queue = append(queue, currentTime)
-		rl.requests[key] = queue
-		return true
+	d := Decision{Limit: rl.maxRequests}
+	d.Allowed = len(queue)+cost <= rl.maxRequests
+	if d.Allowed {
+		for n := 0; n < cost; n++ {
+			queue = append(queue, uint64(currentTime.UnixNano()))
+		}
+	} else if need := len(queue) + cost - rl.maxRequests; cost <= rl.maxRequests {
+		// wait until enough of the oldest requests slide out of the window
+		d.RetryAfter = time.Unix(0, int64(queue[need-1])).Add(rl.windowSize).Sub(currentTime)
+	}
+	d.Remaining = rl.maxRequests - len(queue)
+	d.ResetAt = currentTime
+	if len(queue) > 0 {
+		d.ResetAt = time.Unix(0, int64(queue[len(queue)-1])).Add(rl.windowSize)
 	}
-	return false
+	return encodeState(queue...), d
+}
+
+// refund drops the n most recent requests from the log
+func (rl *slidingWindowRatelimiter) refund(ctx context.Context, key string, n int) error {
+	return rl.update(ctx, key, rl.windowSize, func(state []byte, currentTime time.Time) []byte {
+		queue := decodeWords(state)
+		return encodeState(queue[:max(len(queue)-n, 0)]...)
+	})
 }
@@ -0,0 +1,48 @@
+package rate_limiter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Store holds per-key limiter state. Sharing one Store between processes (e.g. RedisStore)
+// makes N limiter instances enforce a single global limit per key.
+type Store interface {
+	// Update atomically replaces the value of key with fn(current). current is nil when the key
+	// is missing or expired. The new value is kept for ttl (0 means no expiry).
+	// fn may be called more than once if a concurrent writer wins, so it must be side-effect free.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error
+}
+
+var ErrStoreConflict = errors.New("store: too many conflicting updates")
+
+// ------------------- state encoding ------------------
+
+// limiter state is a fixed number of 64-bit words so it can be stored in any byte-oriented Store
+
+func encodeState(words ...uint64) []byte {
+	b := make([]byte, 8*len(words))
+	for i, w := range words {
+		binary.BigEndian.PutUint64(b[8*i:], w)
+	}
+	return b
+}
+
+// decodeState returns ok=false if b is missing or malformed, callers then start from a fresh state
+func decodeState(b []byte, n int) ([]uint64, bool) {
+	if len(b) != 8*n {
+		return nil, false
+	}
+	return decodeWords(b), true
+}
+
+// decodeWords decodes a variable number of words, trailing partial words are ignored
+func decodeWords(b []byte) []uint64 {
+	words := make([]uint64, len(b)/8)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	return words
+}
@@ -1,48 +1,123 @@
 package rate_limiter
 
 import (
+	"context"
 	"math"
 	"time"
 )
 
+// tokenBucketRatelimiter refills bucketSize tokens every refillEvery, continuously
+// (i.e. one token every refillEvery/bucketSize), and never holds more than bucketSize
 type tokenBucketRatelimiter struct {
+	base
 	bucketSize  int
 	refillEvery time.Duration
-	requests    map[string]tokenData
 }
 
-type tokenData struct {
-	freeTokens           int
-	lastFillingTimestamp time.Time
-}
+// token bucket state: [freeTokens (float64 bits), lastFillingTimestamp (unix nanos)]
 
-func NewTokenBucketRatelimiter(bucketSize int, refillEvery time.Duration) *tokenBucketRatelimiter {
+func NewTokenBucketRatelimiter(bucketSize int, refillEvery time.Duration, options ...Option) *tokenBucketRatelimiter {
+	b, _ := newBase("token_bucket:", options)
 	return &tokenBucketRatelimiter{
+		base:        b,
 		bucketSize:  bucketSize,
 		refillEvery: refillEvery,
-		requests:    make(map[string]tokenData),
 	}
 }
 
 func (rl *tokenBucketRatelimiter) AllowRequest(key string) bool {
-	currentTime := time.Now()
-	token, exists := rl.requests[key]
-	if !exists {
-		rl.requests[key] = tokenData{
-			freeTokens:           rl.bucketSize - 1,
-			lastFillingTimestamp: currentTime,
+	return allowRequest(rl, key)
+}
+
+func (rl *tokenBucketRatelimiter) Decide(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.decide(ctx, key, cost, rl.stateTTL(), rl.step)
+}
+
+func (rl *tokenBucketRatelimiter) peek(ctx context.Context, key string, cost int) (Decision, error) {
+	return rl.dryRun(ctx, key, cost, rl.step)
+}
+
+func (rl *tokenBucketRatelimiter) AllowN(key string, n int) bool {
+	return allowN(rl, key, n)
+}
+
+func (rl *tokenBucketRatelimiter) Wait(ctx context.Context, key string, n int) error {
+	return wait(ctx, rl, key, n, rl.now)
+}
+
+// Reserve takes n tokens, letting the bucket go negative by at most bucketSize
+func (rl *tokenBucketRatelimiter) Reserve(ctx context.Context, key string, n int) (*Reservation, error) {
+	if n < 0 {
+		return nil, ErrInvalidCost
+	}
+	if n > rl.bucketSize {
+		return &Reservation{}, ErrCostExceedsLimit
+	}
+	r := &Reservation{tokens: n}
+	err := rl.update(ctx, key, rl.stateTTL(), func(state []byte, currentTime time.Time) []byte {
+		freeTokens := rl.refill(state, currentTime)
+		r.timeToAct = currentTime
+		r.ok = freeTokens-float64(n) >= -float64(rl.bucketSize)
+		if r.ok {
+			freeTokens -= float64(n)
+			if freeTokens < 0 {
+				r.timeToAct = currentTime.Add(rl.timeToRefill(-freeTokens))
+			}
 		}
-		return true
+		return encodeState(math.Float64bits(freeTokens), uint64(currentTime.UnixNano()))
+	})
+	if err != nil {
+		return nil, err
 	}
-	newTokens := float64((currentTime.Sub(token.lastFillingTimestamp))/rl.refillEvery) * float64(rl.bucketSize)
-	if newTokens > 0 {
-		token.lastFillingTimestamp = currentTime
-		token.freeTokens = int(math.Max(float64(token.freeTokens)+newTokens, float64(rl.bucketSize)))
+	r.refund = func(ctx context.Context) error {
+		return rl.refund(ctx, key, n)
+	}
+	return r, nil
+}
+
+// refund puts n tokens back, up to bucketSize
+func (rl *tokenBucketRatelimiter) refund(ctx context.Context, key string, n int) error {
+	return rl.update(ctx, key, rl.stateTTL(), func(state []byte, currentTime time.Time) []byte {
+		freeTokens := math.Min(rl.refill(state, currentTime)+float64(n), float64(rl.bucketSize))
+		return encodeState(math.Float64bits(freeTokens), uint64(currentTime.UnixNano()))
+	})
+}
+
+func (rl *tokenBucketRatelimiter) step(state []byte, currentTime time.Time, cost int) ([]byte, Decision) {
+	freeTokens := rl.refill(state, currentTime)
+	d := Decision{Limit: rl.bucketSize}
+	d.Allowed = freeTokens >= float64(cost)
+	if d.Allowed {
+		freeTokens -= float64(cost)
+	} else {
+		d.RetryAfter = rl.timeToRefill(float64(cost) - freeTokens)
+	}
+	d.Remaining = max(int(freeTokens), 0)
+	d.ResetAt = currentTime.Add(rl.timeToRefill(float64(rl.bucketSize) - freeTokens))
+	return encodeState(math.Float64bits(freeTokens), uint64(currentTime.UnixNano())), d
+}
+
+// stateTTL is how long an untouched bucket takes to refill completely, even from the maximum
+// reservation debt of -bucketSize; after that its state can expire
+func (rl *tokenBucketRatelimiter) stateTTL() time.Duration {
+	return 2 * rl.refillEvery
+}
+
+// timeToRefill is how long it takes to refill n tokens
+func (rl *tokenBucketRatelimiter) timeToRefill(n float64) time.Duration {
+	return time.Duration(math.Ceil(n / float64(rl.bucketSize) * float64(rl.refillEvery)))
+}
+
+// refill returns the tokens available at currentTime
+func (rl *tokenBucketRatelimiter) refill(state []byte, currentTime time.Time) float64 {
+	w, ok := decodeState(state, 2)
+	if !ok {
+		return float64(rl.bucketSize)
 	}
-	if token.freeTokens > 0 {
-		token.freeTokens--
-		rl.requests[key] = token
-		return true
+	freeTokens := math.Float64frombits(w[0])
+	elapsed := currentTime.Sub(time.Unix(0, int64(w[1])))
+	if elapsed > 0 {
+		freeTokens += float64(elapsed) / float64(rl.refillEvery) * float64(rl.bucketSize)
 	}
-	return false
+	return math.Min(freeTokens, float64(rl.bucketSize))
 }